@@ -0,0 +1,827 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	MERGE_PATCH_MEDIA_TYPE = "application/merge-patch+json"
+	DISPLAY_NAME_MAX_CHARS = 64
+	TENANT_MAX_CHARS       = 64
+	ACCOUNT_ROLE_MAX_CHARS = 32
+	DEFAULT_PURGE_GRACE    = 30 * 24 * time.Hour
+	DEFAULT_PAGE_SIZE      = 50
+	MAX_PAGE_SIZE          = 200
+)
+
+var (
+	OWNER_PATCHABLE_FIELDS = []string{"display_name", "email"}
+	ADMIN_PATCHABLE_FIELDS = []string{"display_name", "email", "roles", "tenant"}
+)
+
+type requestRejection struct {
+	status   int
+	response model.ErrorResponse
+}
+
+// decodePatchValue validates the merge patch value of an account field; a nil result removes the field.
+func decodePatchValue(field string, raw json.RawMessage) (any, error) {
+	if string(raw) == "null" {
+		if field == "email" {
+			return nil, errors.New("email cannot be removed")
+		}
+		return nil, nil
+	}
+
+	switch field {
+	case "display_name", "tenant":
+		maxChars := DISPLAY_NAME_MAX_CHARS
+		if field == "tenant" {
+			maxChars = TENANT_MAX_CHARS
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, errors.New(field + " must be a string")
+		}
+		value = strings.TrimSpace(value)
+		if value == "" || utf8.RuneCountInString(value) > maxChars {
+			return nil, fmt.Errorf("%s must hold between 1 and %d characters", field, maxChars)
+		}
+		return value, nil
+
+	case "email":
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, errors.New("email must be a string")
+		}
+		if _, err := model.NormalizeEmail(value); err != nil || strings.TrimSpace(value) != value {
+			return nil, errors.New("email must be a bare address")
+		}
+		return value, nil
+
+	case "roles":
+		var value []string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, errors.New("roles must be an array of strings")
+		}
+		for _, role := range value {
+			if role == "" || utf8.RuneCountInString(role) > ACCOUNT_ROLE_MAX_CHARS {
+				return nil, fmt.Errorf("roles must hold between 1 and %d characters", ACCOUNT_ROLE_MAX_CHARS)
+			}
+		}
+		slices.Sort(value)
+		return slices.Compact(value), nil
+	}
+	return nil, errors.New(field + " cannot be changed")
+}
+
+// accountChangesFromPatch applies the field allow list of the caller to an RFC 7396 merge patch.
+func accountChangesFromPatch(patch map[string]json.RawMessage, allowed []string) (model.AccountChanges, *requestRejection) {
+	var changes model.AccountChanges
+
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		if !slices.Contains(ADMIN_PATCHABLE_FIELDS, field) {
+			return changes, &requestRejection{http.StatusBadRequest, model.ErrorResponse{Reason: "PATCH_FIELD_UNKNOWN", Message: "Field cannot be patched: " + field}}
+		}
+		if !slices.Contains(allowed, field) {
+			return changes, &requestRejection{http.StatusForbidden, model.ErrorResponse{Reason: "PATCH_FIELD_FORBIDDEN", Message: "Not permitted to change field: " + field}}
+		}
+
+		value, decodeErr := decodePatchValue(field, patch[field])
+		if decodeErr != nil {
+			return changes, &requestRejection{http.StatusBadRequest, model.ErrorResponse{Reason: "PATCH_VALUE_INVALID", Message: decodeErr.Error()}}
+		}
+		if value == nil {
+			changes.Unset = append(changes.Unset, field)
+		} else {
+			changes.Set = append(changes.Set, bson.E{Key: field, Value: value})
+		}
+		if field == "email" {
+			changes.Set = append(changes.Set, bson.E{Key: "email_verified", Value: false})
+		}
+	}
+	return changes, nil
+}
+
+func (provider *TournabyteIdentityProviderService) updateAccount(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || !principal.CanManage(idHex) {
+		log.Printf("Principal may not update account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+
+	oid, convertIdErr := bson.ObjectIDFromHex(idHex)
+	if convertIdErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PATH_PARAMETER_MALFORMED", Message: "Given hex is not a valid object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("ID parameter invalid")
+	}
+
+	patch, _ := r.Context().Value(DECODED_JSON_BODY).(map[string]json.RawMessage)
+	if patch == nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "INVALID_JSON_BODY", Message: "Merge patch must be a JSON object"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Merge patch is not an object")
+	}
+
+	allowed := OWNER_PATCHABLE_FIELDS
+	if principal.IsAdmin() {
+		allowed = ADMIN_PATCHABLE_FIELDS
+	}
+	changes, rejection := accountChangesFromPatch(patch, allowed)
+	if rejection != nil {
+		log.Printf("Rejecting merge patch for account %s: %s", idHex, rejection.response.Message)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, rejection.status),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				rejection.response,
+			))
+		defer RecoverResponse(w, r)
+		panic("Merge patch rejected")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	updateErr := accountsCollectionHandle.Update(r.Context(), oid, changes)
+	var account *model.Account
+	if updateErr == nil {
+		account, updateErr = accountsCollectionHandle.FindById(r.Context(), idHex)
+	}
+
+	switch {
+	case errors.Is(updateErr, model.ErrAccountExists):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_EXISTS", Message: "An account with this email already exists"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Duplicate account")
+
+	case errors.Is(updateErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusNotFound),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "No resource found for the given object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Resource not found")
+
+	case updateErr != nil:
+		log.Printf("Failed to update account %s: %v", idHex, updateErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_UPDATED", Message: "Account could not be updated"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account update failed")
+
+	default:
+		if _, emailChanged := patch["email"]; emailChanged {
+			provider.sendEmailVerification(r.Context(), account)
+		}
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				account.BasicInfo(),
+			))
+		EmitResponseAsJSON[model.BasicAccountInfoResponse](w, r)
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) purgeGracePeriod() time.Duration {
+	if grace := provider.env.Accounts.PurgeGracePeriod; grace > 0 {
+		return grace
+	}
+	return DEFAULT_PURGE_GRACE
+}
+
+func (provider *TournabyteIdentityProviderService) deactivateAccount(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || !principal.CanManage(idHex) {
+		log.Printf("Principal may not deactivate account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+
+	oid, convertIdErr := bson.ObjectIDFromHex(idHex)
+	if convertIdErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PATH_PARAMETER_MALFORMED", Message: "Given hex is not a valid object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("ID parameter invalid")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	deactivatedAt, deactivateErr := accountsCollectionHandle.Deactivate(r.Context(), oid)
+	if deactivateErr == nil {
+		deactivateErr = provider.revokeAccountSessions(r.Context(), oid)
+	}
+
+	switch {
+	case errors.Is(deactivateErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusNotFound),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "No resource found for the given object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Resource not found")
+
+	case deactivateErr != nil:
+		log.Printf("Failed to deactivate account %s: %v", idHex, deactivateErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_DEACTIVATED", Message: "Account could not be deactivated"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account deactivation failed")
+
+	default:
+		log.Printf("Deactivated account %s and revoked its sessions", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.AccountDeactivationResponse{
+					AccountIdentifier: oid,
+					DeactivatedAt:     deactivatedAt,
+					PurgeAfter:        deactivatedAt.Add(provider.purgeGracePeriod()),
+				},
+			))
+		EmitResponseAsJSON[model.AccountDeactivationResponse](w, r)
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) purgeAccount(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || !principal.IsAdmin() {
+		log.Printf("Principal may not purge account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+
+	oid, convertIdErr := bson.ObjectIDFromHex(idHex)
+	if convertIdErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PATH_PARAMETER_MALFORMED", Message: "Given hex is not a valid object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("ID parameter invalid")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	// FindById only matches active accounts, so a miss is expected for the deactivated ones that may be purged.
+	_, findErr := accountsCollectionHandle.FindById(r.Context(), idHex)
+	switch {
+	case findErr == nil:
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_ACTIVE", Message: "Only deactivated accounts can be purged"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Purge of an active account")
+
+	case !errors.Is(findErr, mongo.ErrNoDocuments):
+		log.Printf("Failed to look up account %s before purging: %v", idHex, findErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_PURGED", Message: "Account could not be purged"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account purge failed")
+	}
+
+	purgeErr := accountsCollectionHandle.Purge(r.Context(), oid, time.Now().Add(-provider.purgeGracePeriod()))
+	switch {
+	case errors.Is(purgeErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusNotFound),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "No account past its grace period found for the given object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Resource not found")
+
+	case purgeErr != nil:
+		log.Printf("Failed to purge account %s: %v", idHex, purgeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_PURGED", Message: "Account could not be purged"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account purge failed")
+
+	default:
+		log.Printf("Account %s purged by %s", idHex, principal.Subject)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				struct{}{},
+			))
+		EmitResponseAsJSON[struct{}](w, r)
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) reactivateAccount(w http.ResponseWriter, r *http.Request) {
+	loginAttempt, _ := r.Context().Value(DECODED_JSON_BODY).(model.LoginAttempt)
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	acc, authErr := provider.authenticateLoginAttempt(r.Context(), accountsCollectionHandle, loginAttempt)
+
+	switch {
+	case authErr == nil:
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_ACTIVE", Message: "Account is already active"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Reactivation of an active account")
+
+	case errors.Is(authErr, errAccountLocked):
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Reactivation attempt for a locked account")
+
+	case !errors.Is(authErr, errAccountDeactivated):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "Invalid email or password"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid reactivation attempt")
+	}
+
+	// Reactivating lets the account log in again, so it demands the second factor like POST /oauth2/authorize does.
+	if acc.MFAEnabled {
+		if loginAttempt.MFACode == "" {
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "MFA_REQUIRED", Message: "A code from the enrolled authenticator is required"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Reactivation attempt without a second factor")
+		}
+
+		switch verifyErr := provider.verifySecondFactor(r.Context(), accountsCollectionHandle, acc, loginAttempt.MFACode); {
+		case errors.Is(verifyErr, errAccountLocked):
+			setRetryAfter(w, acc.LockedUntil)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Reactivation attempt for a locked account")
+
+		case verifyErr != nil:
+			log.Printf("Second factor of account %s not verified: %v", acc.Id.Hex(), verifyErr)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "MFA_CODE_INVALID", Message: "Code is invalid or was already used"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Invalid MFA code")
+		}
+	}
+
+	reactivateErr := accountsCollectionHandle.Reactivate(r.Context(), acc.Id, time.Now().Add(-provider.purgeGracePeriod()))
+	switch {
+	case errors.Is(reactivateErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusGone),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "GRACE_PERIOD_EXPIRED", Message: "Account can no longer be reactivated"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Reactivation after the grace period")
+
+	case reactivateErr != nil:
+		log.Printf("Failed to reactivate account %s: %v", acc.Id.Hex(), reactivateErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_REACTIVATED", Message: "Account could not be reactivated"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account reactivation failed")
+	}
+
+	acc.Active = true
+	acc.DeactivatedAt = time.Time{}
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			acc.BasicInfo(),
+		))
+	EmitResponseAsJSON[model.BasicAccountInfoResponse](w, r)
+}
+
+func parseOptionalBool(params map[string]string, name string) (*bool, error) {
+	raw, ok := params[name]
+	if !ok {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, errors.New(name + " must be true or false")
+	}
+	return &value, nil
+}
+
+func parseOptionalTime(params map[string]string, name string) (time.Time, error) {
+	raw, ok := params[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return value, nil
+}
+
+func accountQueryFromParams(params map[string]string) (model.AccountQuery, error) {
+	var err error
+	query := model.AccountQuery{Limit: DEFAULT_PAGE_SIZE, EmailPrefix: params["email_prefix"]}
+
+	if raw, ok := params["limit"]; ok {
+		if query.Limit, err = strconv.Atoi(raw); err != nil || query.Limit < 1 || query.Limit > MAX_PAGE_SIZE {
+			return query, errors.New("limit must be between 1 and 200")
+		}
+	}
+	if raw, ok := params["cursor"]; ok {
+		if query.After, err = bson.ObjectIDFromHex(raw); err != nil {
+			return query, errors.New("cursor is not valid")
+		}
+	}
+	switch params["order"] {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+	if query.Active, err = parseOptionalBool(params, "active"); err != nil {
+		return query, err
+	}
+	if query.Locked, err = parseOptionalBool(params, "locked"); err != nil {
+		return query, err
+	}
+	if query.CreatedAfter, err = parseOptionalTime(params, "created_after"); err != nil {
+		return query, err
+	}
+	if query.CreatedBefore, err = parseOptionalTime(params, "created_before"); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func (provider *TournabyteIdentityProviderService) listAccounts(w http.ResponseWriter, r *http.Request) {
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || !principal.IsAdmin() {
+		log.Printf("Principal may not list accounts")
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+
+	params, _ := r.Context().Value(QUERY_VALUE_MAPPING).(map[string]string)
+	query, queryErr := accountQueryFromParams(params)
+	if queryErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "QUERY_PARAMETER_MALFORMED", Message: queryErr.Error()},
+			))
+		defer RecoverResponse(w, r)
+		panic("Query parameter malformed")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	accounts, more, listErr := accountsCollectionHandle.List(r.Context(), query)
+	if listErr != nil {
+		log.Printf("Failed to list accounts: %v", listErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNTS_NOT_LISTED", Message: "Accounts could not be listed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account listing failed")
+	}
+
+	response := model.AccountListResponse{Accounts: make([]model.AccountListEntry, 0, len(accounts))}
+	for i := range accounts {
+		response.Accounts = append(response.Accounts, accounts[i].ListEntry())
+	}
+	if more {
+		response.NextCursor = accounts[len(accounts)-1].Id.Hex()
+
+		next := r.URL.Query()
+		next.Set("cursor", response.NextCursor)
+		link := url.URL{Path: endpointPath(LIST_ACCOUNTS_ENDPOINT), RawQuery: next.Encode()}
+		w.Header().Set("Link", "<"+provider.tokenIssuer()+link.String()+`>; rel="next"`)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			response,
+		))
+	EmitResponseAsJSON[model.AccountListResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) unlockAccount(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || !principal.IsAdmin() {
+		log.Printf("Principal may not unlock account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+
+	oid, convertIdErr := bson.ObjectIDFromHex(idHex)
+	if convertIdErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PATH_PARAMETER_MALFORMED", Message: "Given hex is not a valid object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("ID parameter invalid")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	unlockErr := accountsCollectionHandle.Unlock(r.Context(), oid)
+	switch {
+	case errors.Is(unlockErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusNotFound),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "No resource found for the given object ID"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Resource not found")
+
+	case unlockErr != nil:
+		log.Printf("Failed to unlock account %s: %v", idHex, unlockErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_NOT_UNLOCKED", Message: "Account could not be unlocked"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Account unlock failed")
+
+	default:
+		log.Printf("Account %s unlocked by %s", idHex, principal.Subject)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				struct{}{},
+			))
+		EmitResponseAsJSON[struct{}](w, r)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type AccountPurgeTestSuite struct {
+	suite.Suite
+	accounts *MockCollectionHandle
+	provider *TournabyteIdentityProviderService
+	admin    *AuthenticatedPrincipal
+}
+
+func TestAccountPurge(t *testing.T) {
+	suite.Run(t, new(AccountPurgeTestSuite))
+}
+
+func (s *AccountPurgeTestSuite) SetupTest() {
+	s.accounts = new(MockCollectionHandle)
+	s.provider = &TournabyteIdentityProviderService{
+		env:         &model.ApplicationOptions{},
+		collections: mockCollections(map[string]*MockCollectionHandle{"accounts": s.accounts}),
+	}
+	s.admin = &AuthenticatedPrincipal{Subject: bson.NewObjectID().Hex(), Account: &model.Account{Roles: []string{ROLE_ADMIN}}}
+}
+
+func (s *AccountPurgeTestSuite) purge(idHex string) (*httptest.ResponseRecorder, model.ErrorResponse) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/accounts/"+idHex+"/purge", nil)
+	r = r.WithContext(context.WithValue(r.Context(), PATH_VALUE_MAPPING, map[string]string{"id": idHex}))
+	r = r.WithContext(context.WithValue(r.Context(), AUTHENTICATED_PRINCIPAL, s.admin))
+	s.provider.purgeAccount(w, r)
+
+	var body model.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&body)
+	return w, body
+}
+
+func (s *AccountPurgeTestSuite) TestFailedLookupIsServerError() {
+	s.accounts.On("FindOne", mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&model.Account{}, errors.New("server selection timeout"), nil),
+	)
+
+	w, body := s.purge(bson.NewObjectID().Hex())
+
+	assert.Equal(s.T(), http.StatusInternalServerError, w.Code)
+	assert.Equal(s.T(), "ACCOUNT_NOT_PURGED", body.Reason)
+	s.accounts.AssertNotCalled(s.T(), "DeleteOne", mock.Anything, mock.Anything)
+}
+
+func (s *AccountPurgeTestSuite) TestActiveAccountIsConflict() {
+	oid := bson.NewObjectID()
+	s.accounts.On("FindOne", mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&model.Account{Id: oid, Active: true}, nil, nil),
+	)
+
+	w, body := s.purge(oid.Hex())
+
+	assert.Equal(s.T(), http.StatusConflict, w.Code)
+	assert.Equal(s.T(), "ACCOUNT_ACTIVE", body.Reason)
+}
+
+type AccountReactivationTestSuite struct {
+	suite.Suite
+	accounts *MockCollectionHandle
+	provider *TournabyteIdentityProviderService
+}
+
+func TestAccountReactivation(t *testing.T) {
+	suite.Run(t, new(AccountReactivationTestSuite))
+}
+
+func (s *AccountReactivationTestSuite) SetupTest() {
+	s.accounts = new(MockCollectionHandle)
+	s.provider = &TournabyteIdentityProviderService{
+		env:         &model.ApplicationOptions{},
+		collections: mockCollections(map[string]*MockCollectionHandle{"accounts": s.accounts}),
+	}
+}
+
+func (s *AccountReactivationTestSuite) TestSecondFactorIsRequired() {
+	loginKey, err := argon2id.CreateHash("correct horse battery staple", argon2id.DefaultParams)
+	s.Require().NoError(err)
+	deactivated := model.Account{Id: bson.NewObjectID(), Email: "player@example.io", LoginKey: loginKey, MFAEnabled: true}
+	s.accounts.On("FindOne", mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&deactivated, nil, nil))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/accounts/reactivate", nil)
+	r = r.WithContext(context.WithValue(r.Context(), DECODED_JSON_BODY, model.LoginAttempt{
+		LoginId:     "player@example.io",
+		LoginSecret: "correct horse battery staple",
+	}))
+	s.provider.reactivateAccount(w, r)
+
+	var body model.ErrorResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(s.T(), http.StatusUnauthorized, w.Code)
+	assert.Equal(s.T(), "MFA_REQUIRED", body.Reason)
+	s.accounts.AssertNotCalled(s.T(), "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,135 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	SCOPE_ACCOUNTS_READ  = "accounts:read"
+	SCOPE_ACCOUNTS_WRITE = "accounts:write"
+	ROLE_ADMIN           = "admin"
+)
+
+type AuthenticatedPrincipal struct {
+	Subject   string
+	ClientId  string
+	TokenId   string
+	Scopes    []string
+	ExpiresAt time.Time
+	Account   *model.Account
+}
+
+func (p *AuthenticatedPrincipal) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(p.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *AuthenticatedPrincipal) IsAdmin() bool {
+	return p.Account != nil && slices.Contains(p.Account.Roles, ROLE_ADMIN)
+}
+
+func (p *AuthenticatedPrincipal) CanManage(idHex string) bool {
+	return p.Subject == idHex || p.IsAdmin()
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func (provider *TournabyteIdentityProviderService) RequireBearerToken(scopes ...string) HandlerFuncProcessingStep {
+	challenge := `Bearer realm="tournabyte"`
+	if len(scopes) > 0 {
+		challenge = fmt.Sprintf(`Bearer realm="tournabyte", scope="%s"`, strings.Join(scopes, " "))
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			raw, found := bearerToken(r)
+			if !found {
+				log.Printf("Request to a protected route carried no bearer token")
+				w.Header().Set("WWW-Authenticate", challenge)
+				r = r.WithContext(
+					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+				)
+				r = r.WithContext(
+					context.WithValue(
+						r.Context(),
+						HANDLER_RESPONSE_BODY,
+						model.ErrorResponse{Reason: "BEARER_TOKEN_REQUIRED", Message: "An Authorization: Bearer token is required"},
+					))
+				defer RecoverResponse(w, r)
+				panic("Missing bearer token")
+			}
+
+			claims, acc, verifyErr := provider.verifyAccessToken(r.Context(), raw)
+			if verifyErr != nil {
+				log.Printf("Rejected bearer token: %v", verifyErr)
+				w.Header().Set("WWW-Authenticate", challenge+`, error="invalid_token"`)
+				r = r.WithContext(
+					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+				)
+				r = r.WithContext(
+					context.WithValue(
+						r.Context(),
+						HANDLER_RESPONSE_BODY,
+						model.ErrorResponse{Reason: "BEARER_TOKEN_INVALID", Message: "Bearer token is invalid, expired or revoked"},
+					))
+				defer RecoverResponse(w, r)
+				panic("Invalid bearer token")
+			}
+
+			principal := AuthenticatedPrincipal{
+				Subject:  claims.Subject,
+				ClientId: claims.ClientId,
+				TokenId:  claims.ID,
+				Scopes:   splitScope(claims.Scope),
+				Account:  acc,
+			}
+			if claims.Expiry != nil {
+				principal.ExpiresAt = claims.Expiry.Time()
+			}
+
+			if !principal.HasScopes(scopes...) {
+				log.Printf("Bearer token for %s lacks the required scopes %v", principal.Subject, scopes)
+				w.Header().Set("WWW-Authenticate", challenge+`, error="insufficient_scope"`)
+				r = r.WithContext(
+					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+				)
+				r = r.WithContext(
+					context.WithValue(
+						r.Context(),
+						HANDLER_RESPONSE_BODY,
+						model.ErrorResponse{Reason: "INSUFFICIENT_SCOPE", Message: "Bearer token was not granted the required scope"},
+					))
+				defer RecoverResponse(w, r)
+				panic("Insufficient scope")
+			}
+
+			next(w, r.WithContext(
+				context.WithValue(
+					r.Context(),
+					AUTHENTICATED_PRINCIPAL,
+					&principal,
+				)))
+		}
+	}
+}
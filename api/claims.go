@@ -0,0 +1,79 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	ACCESS_TOKEN_TYPE = "access_token"
+	ID_TOKEN_TYPE     = "id_token"
+)
+
+// RESERVED_CLAIMS are set by the provider itself and cannot be contributed by a ClaimsEnricher.
+var RESERVED_CLAIMS = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "scope", "client_id", "auth_time", "nonce", "azp"}
+
+// TokenIssuance describes the token a ClaimsEnricher is contributing to.
+type TokenIssuance struct {
+	TokenType string
+	ClientId  string
+	Scopes    []string
+}
+
+// ClaimsEnricher contributes custom claims to tokens at issuance time. Enrichers run in registration
+// order and later enrichers override claims of earlier ones. An error aborts the issuance.
+type ClaimsEnricher interface {
+	EnrichClaims(ctx context.Context, acc *model.Account, issuance TokenIssuance) (map[string]any, error)
+}
+
+type ClaimsEnricherFunc func(ctx context.Context, acc *model.Account, issuance TokenIssuance) (map[string]any, error)
+
+func (f ClaimsEnricherFunc) EnrichClaims(ctx context.Context, acc *model.Account, issuance TokenIssuance) (map[string]any, error) {
+	return f(ctx, acc, issuance)
+}
+
+// accountClaims places the authorization attributes held on the account into access tokens.
+func accountClaims(ctx context.Context, acc *model.Account, issuance TokenIssuance) (map[string]any, error) {
+	claims := map[string]any{}
+	if issuance.TokenType != ACCESS_TOKEN_TYPE {
+		return claims, nil
+	}
+
+	if len(acc.Roles) > 0 {
+		claims["roles"] = acc.Roles
+	}
+	if acc.Tenant != "" {
+		claims["tenant"] = acc.Tenant
+	}
+	if acc.DisplayName != "" && slices.Contains(issuance.Scopes, SCOPE_PROFILE) {
+		claims["name"] = acc.DisplayName
+	}
+	return claims, nil
+}
+
+func (provider *TournabyteIdentityProviderService) AddClaimsEnricher(enricher ClaimsEnricher) {
+	provider.claimsEnrichers = append(provider.claimsEnrichers, enricher)
+}
+
+func (provider *TournabyteIdentityProviderService) enrichClaims(ctx context.Context, acc *model.Account, issuance TokenIssuance) (map[string]any, error) {
+	claims := map[string]any{}
+	for _, enricher := range provider.claimsEnrichers {
+		contributed, err := enricher.EnrichClaims(ctx, acc, issuance)
+		if err != nil {
+			return nil, fmt.Errorf("claims enricher %T failed: %w", enricher, err)
+		}
+		for name, value := range contributed {
+			if slices.Contains(RESERVED_CLAIMS, name) {
+				return nil, fmt.Errorf("claims enricher %T attempted to set reserved claim %q", enricher, name)
+			}
+			claims[name] = value
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,63 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type MockCollectionHandle struct {
+	mock.Mock
+}
+
+func (m *MockCollectionHandle) InsertOne(ctx context.Context, doc any, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error) {
+	args := m.Called(ctx, doc)
+	return args.Get(0).(*mongo.InsertOneResult), args.Error(1)
+}
+
+func (m *MockCollectionHandle) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) *mongo.SingleResult {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.SingleResult)
+}
+
+func (m *MockCollectionHandle) UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.UpdateResult), args.Error(1)
+}
+
+func (m *MockCollectionHandle) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.SingleResult)
+}
+
+func (m *MockCollectionHandle) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.Cursor), args.Error(1)
+}
+
+func (m *MockCollectionHandle) DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.DeleteResult), args.Error(1)
+}
+
+func (m *MockCollectionHandle) UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error) {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.UpdateResult), args.Error(1)
+}
+
+// mockCollections serves the named mocks in place of the idp database; any other collection fails the test through
+// a mock without expectations.
+func mockCollections(mocks map[string]*MockCollectionHandle) func(string) model.DocumentCollection {
+	return func(name string) model.DocumentCollection {
+		if handle, ok := mocks[name]; ok {
+			return handle
+		}
+		return new(MockCollectionHandle)
+	}
+}
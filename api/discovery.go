@@ -0,0 +1,83 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	DISCOVERY_CACHE_TIME = "public, max-age=3600"
+	KEY_SET_MAX_AGE      = 5 * time.Minute
+)
+
+var (
+	SUPPORTED_SCOPES      = []string{SCOPE_OPENID, SCOPE_EMAIL, SCOPE_PROFILE, SCOPE_ACCOUNTS_READ, SCOPE_ACCOUNTS_WRITE}
+	SUPPORTED_GRANT_TYPES = []string{"authorization_code", "refresh_token"}
+)
+
+func (provider *TournabyteIdentityProviderService) tokenIssuer() string {
+	return provider.tokens.issuer
+}
+
+func endpointPath(pattern string) string {
+	_, path, _ := strings.Cut(pattern, " ")
+	return path
+}
+
+func (provider *TournabyteIdentityProviderService) describeProvider(w http.ResponseWriter, r *http.Request) {
+	base := provider.tokenIssuer()
+
+	w.Header().Set("Cache-Control", DISCOVERY_CACHE_TIME)
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.OpenIDProviderMetadata{
+				Issuer:                            provider.tokenIssuer(),
+				AuthorizationEndpoint:             base + endpointPath(OAUTH_AUTHORIZE),
+				TokenEndpoint:                     base + endpointPath(OAUTH_TOKEN),
+				RevocationEndpoint:                base + endpointPath(OAUTH_REVOKE),
+				IntrospectionEndpoint:             base + endpointPath(OAUTH_INTROSPECT),
+				UserinfoEndpoint:                  base + endpointPath(USERINFO),
+				JwksURI:                           base + endpointPath(JSON_WEB_KEY_SET),
+				ScopesSupported:                   SUPPORTED_SCOPES,
+				ResponseTypesSupported:            []string{"code"},
+				GrantTypesSupported:               SUPPORTED_GRANT_TYPES,
+				SubjectTypesSupported:             []string{"public"},
+				IdTokenSigningAlgValuesSupported:  []string{string(provider.signingKeys.Algorithm())},
+				TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+				CodeChallengeMethodsSupported:     []string{PKCE_METHOD_S256},
+				ClaimsSupported:                   SUPPORTED_CLAIMS,
+			},
+		))
+	EmitResponseAsJSON[model.OpenIDProviderMetadata](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) publishSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keySet := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	keySet.Keys = append(keySet.Keys, provider.signingKeys.VerificationKeys()...)
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(KEY_SET_MAX_AGE.Seconds())))
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			keySet,
+		))
+	EmitResponseAsJSON[jose.JSONWebKeySet](w, r)
+}
@@ -0,0 +1,132 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tournabyte/idp/model"
+)
+
+func (provider *TournabyteIdentityProviderService) introspectToken(w http.ResponseWriter, r *http.Request) {
+	form, ok := r.Context().Value(DECODED_FORM_BODY).(url.Values)
+	if !ok || form.Get("token") == "" {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_request", Description: "Required token parameter is not present"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid introspection request")
+	}
+
+	client, clientErr := provider.authenticateClient(r.Context(), r, form)
+	if clientErr != nil || client.Public {
+		if _, _, basicAuth := r.BasicAuth(); basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_client", Description: "Introspection requires an authenticated confidential client"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid introspection request")
+	}
+
+	var response model.IntrospectionResponse
+	if form.Get("token_type_hint") == "refresh_token" {
+		response = provider.introspectRefreshToken(r.Context(), form.Get("token"))
+		if !response.Active {
+			response = provider.introspectAccessToken(r.Context(), form.Get("token"))
+		}
+	} else {
+		response = provider.introspectAccessToken(r.Context(), form.Get("token"))
+		if !response.Active {
+			response = provider.introspectRefreshToken(r.Context(), form.Get("token"))
+		}
+	}
+	log.Printf("Client %s introspected a token, active=%t", client.Id, response.Active)
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			response,
+		))
+	EmitResponseAsJSON[model.IntrospectionResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) introspectAccessToken(ctx context.Context, raw string) model.IntrospectionResponse {
+	claims, _, verifyErr := provider.verifyAccessToken(ctx, raw)
+	if verifyErr != nil {
+		log.Printf("Introspected access token is not active: %v", verifyErr)
+		return model.IntrospectionResponse{Active: false}
+	}
+
+	response := model.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		TokenType: "access_token",
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		TokenId:   claims.ID,
+	}
+	if claims.Expiry != nil {
+		response.ExpiresAt = claims.Expiry.Time().Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Time().Unix()
+	}
+	if claims.NotBefore != nil {
+		response.NotBefore = claims.NotBefore.Time().Unix()
+	}
+	return response
+}
+
+func (provider *TournabyteIdentityProviderService) introspectRefreshToken(ctx context.Context, raw string) model.IntrospectionResponse {
+	refreshTokensCollectionHandle := model.NewTournabyteRefreshTokenRepository(
+		provider.collection("refresh_tokens"),
+	)
+	token, findErr := refreshTokensCollectionHandle.FindByHash(ctx, hashOpaqueToken(raw))
+	if findErr != nil || token.Used || token.Revoked || !token.ExpiresAt.After(time.Now()) {
+		return model.IntrospectionResponse{Active: false}
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	if acc, accountErr := accountsCollectionHandle.FindById(ctx, token.AccountId.Hex()); accountErr != nil || !acc.Active {
+		return model.IntrospectionResponse{Active: false}
+	}
+
+	return model.IntrospectionResponse{
+		Active:    true,
+		Scope:     token.Scope,
+		ClientId:  token.ClientId,
+		TokenType: "refresh_token",
+		Subject:   token.AccountId.Hex(),
+		Issuer:    provider.tokenIssuer(),
+		ExpiresAt: token.ExpiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,330 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	SIGNING_KEY_RING_ID       = "token-signing"
+	KEY_RING_REFRESH_INTERVAL = 1 * time.Minute
+	KEY_RING_SAVE_ATTEMPTS    = 3
+	DEFAULT_KEY_RETENTION     = 25 * time.Hour
+	DEFAULT_KEY_PUBLISH_DELAY = 1 * time.Hour
+)
+
+var errRotationUnsupported = errors.New("key rotation requires an asymmetric signing algorithm")
+
+// signingKeyRing implements jose.Signer by delegating to the currently active key while keeping
+// the public halves of retired keys available for verification until their retention lapses. Rotated
+// keys are published for publishDelay before they sign, so that verifiers caching the key set know them.
+type signingKeyRing struct {
+	mu               sync.RWMutex
+	repo             *model.TournabyteSigningKeyRepository
+	algorithm        jose.SignatureAlgorithm
+	keyFile          string
+	rotationInterval time.Duration
+	retention        time.Duration
+	publishDelay     time.Duration
+	keyEncryptionKey []byte
+	pending          model.SigningKey
+	active           jose.JSONWebKey
+	signer           jose.Signer
+	published        []jose.JSONWebKey
+}
+
+// newSigningKeyRing stores private keys sealed with keyEncryptionKey, or in plaintext when it is nil.
+func newSigningKeyRing(ctx context.Context, repo *model.TournabyteSigningKeyRepository, alg jose.SignatureAlgorithm, keyFile string, secret string, rotationInterval time.Duration, retention time.Duration, publishDelay time.Duration, keyEncryptionKey []byte) (*signingKeyRing, error) {
+	if retention <= 0 {
+		retention = DEFAULT_KEY_RETENTION
+	}
+	if publishDelay <= 0 {
+		publishDelay = DEFAULT_KEY_PUBLISH_DELAY
+	}
+	ring := &signingKeyRing{
+		repo:             repo,
+		algorithm:        alg,
+		keyFile:          keyFile,
+		rotationInterval: rotationInterval,
+		retention:        retention,
+		publishDelay:     publishDelay,
+		keyEncryptionKey: keyEncryptionKey,
+	}
+
+	if !isAsymmetricAlgorithm(alg) {
+		key, err := newSymmetricSigningKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := newTokenSigner(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.active = key
+		ring.signer = signer
+		return ring, nil
+	}
+
+	if err := ring.refresh(ctx, false); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func (ring *signingKeyRing) Sign(payload []byte) (*jose.JSONWebSignature, error) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.signer.Sign(payload)
+}
+
+func (ring *signingKeyRing) Options() jose.SignerOptions {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.signer.Options()
+}
+
+func (ring *signingKeyRing) Algorithm() jose.SignatureAlgorithm {
+	return ring.algorithm
+}
+
+func (ring *signingKeyRing) ActiveKeyId() string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.active.KeyID
+}
+
+// PendingKey reports the published key that will start signing at activatesAt, if a rotation is scheduled.
+func (ring *signingKeyRing) PendingKey() (kid string, activatesAt time.Time, ok bool) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.pending.KeyId, ring.pending.ActivatesAt, ring.pending.KeyId != ""
+}
+
+func (ring *signingKeyRing) VerificationKeys() []jose.JSONWebKey {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return append([]jose.JSONWebKey(nil), ring.published...)
+}
+
+func (ring *signingKeyRing) VerificationKey(kid string) (jose.JSONWebKey, bool) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	if !isAsymmetricAlgorithm(ring.algorithm) {
+		return ring.active, ring.active.KeyID == kid
+	}
+	for _, key := range ring.published {
+		if key.KeyID == kid {
+			return key, true
+		}
+	}
+	return jose.JSONWebKey{}, false
+}
+
+func (ring *signingKeyRing) Rotate(ctx context.Context) error {
+	if !isAsymmetricAlgorithm(ring.algorithm) {
+		return errRotationUnsupported
+	}
+	return ring.refresh(ctx, true)
+}
+
+func (ring *signingKeyRing) Watch(ctx context.Context) {
+	if !isAsymmetricAlgorithm(ring.algorithm) {
+		return
+	}
+
+	ticker := time.NewTicker(KEY_RING_REFRESH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ring.refresh(ctx, false); err != nil {
+				log.Printf("Failed to refresh the signing key ring: %v", err)
+			}
+		}
+	}
+}
+
+func (ring *signingKeyRing) refresh(ctx context.Context, forceRotation bool) error {
+	for attempt := 0; attempt < KEY_RING_SAVE_ATTEMPTS; attempt++ {
+		now := time.Now().UTC()
+		stored, err := ring.repo.Load(ctx, SIGNING_KEY_RING_ID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			stored = &model.SigningKeyRing{Id: SIGNING_KEY_RING_ID}
+		} else if err != nil {
+			return fmt.Errorf("could not load signing keys: %w", err)
+		}
+
+		changed := stored.PruneExpired(now)
+		newest := stored.NewestKey()
+		if forceRotation || ring.rotationDue(newest, now) {
+			next, err := ring.nextKey(len(stored.Keys) == 0)
+			if err != nil {
+				return err
+			}
+			next.CreatedAt = now
+			// Only a ring without a signing key starts signing with the new key right away.
+			activatesAt := now
+			if stored.ActiveKey(now) != nil {
+				activatesAt = now.Add(ring.publishDelay)
+			}
+			stored.ScheduleRotation(next, now, activatesAt, ring.retention)
+			changed = true
+		}
+		sealed, err := ring.sealPlaintextKeys(stored)
+		if err != nil {
+			return err
+		}
+		changed = changed || sealed
+
+		if changed {
+			if err := ring.repo.Save(ctx, stored); errors.Is(err, model.ErrSigningKeyRingConflict) {
+				log.Printf("Signing key ring changed while saving, retrying")
+				continue
+			} else if err != nil {
+				return fmt.Errorf("could not save signing keys: %w", err)
+			}
+			if newest := stored.NewestKey(); newest != nil && newest.IsPending(now) {
+				log.Printf("Published token signing key %s, signing with it from %s", newest.KeyId, newest.ActivatesAt.Format(time.RFC3339))
+			} else if active := stored.ActiveKey(now); active != nil {
+				log.Printf("Now signing tokens with %s key %s", active.Algorithm, active.KeyId)
+			}
+		}
+
+		return ring.install(stored, now)
+	}
+	return model.ErrSigningKeyRingConflict
+}
+
+// rotationDue looks at the newest key so that a rotation is not scheduled again while its key is pending.
+func (ring *signingKeyRing) rotationDue(newest *model.SigningKey, now time.Time) bool {
+	if newest == nil || newest.Algorithm != string(ring.algorithm) {
+		return true
+	}
+	return ring.rotationInterval > 0 && now.Sub(newest.CreatedAt) >= ring.rotationInterval
+}
+
+// sealPlaintextKeys encrypts keys stored before a key-encryption key was configured.
+func (ring *signingKeyRing) sealPlaintextKeys(stored *model.SigningKeyRing) (bool, error) {
+	if ring.keyEncryptionKey == nil {
+		return false, nil
+	}
+
+	sealedAny := false
+	for i := range stored.Keys {
+		record := &stored.Keys[i]
+		if record.PrivateKey == "" {
+			continue
+		}
+		sealed, err := sealPrivateKey(ring.keyEncryptionKey, record.KeyId, []byte(record.PrivateKey))
+		if err != nil {
+			return false, fmt.Errorf("could not encrypt signing key %s: %w", record.KeyId, err)
+		}
+		record.EncryptedPrivateKey = sealed
+		record.PrivateKey = ""
+		sealedAny = true
+	}
+	return sealedAny, nil
+}
+
+func (ring *signingKeyRing) privateKeyPEM(record model.SigningKey) ([]byte, error) {
+	if len(record.EncryptedPrivateKey) == 0 {
+		return []byte(record.PrivateKey), nil
+	}
+	if ring.keyEncryptionKey == nil {
+		return nil, errors.New("key is encrypted but serve.jwt.key_encryption_key is not set")
+	}
+	return openPrivateKey(ring.keyEncryptionKey, record.KeyId, record.EncryptedPrivateKey)
+}
+
+func (ring *signingKeyRing) nextKey(seedFromFile bool) (model.SigningKey, error) {
+	var key crypto.Signer
+	var err error
+
+	if seedFromFile && ring.keyFile != "" {
+		key, err = readPrivateKeyFile(ring.keyFile)
+	} else {
+		key, err = generatePrivateKey(ring.algorithm)
+	}
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+	if !keyMatchesAlgorithm(key, ring.algorithm) {
+		return model.SigningKey{}, fmt.Errorf("signing key of type %T cannot be used with %s", key, ring.algorithm)
+	}
+
+	jwk, err := newSigningKey(key, ring.algorithm)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	return model.SigningKey{
+		KeyId:      jwk.KeyID,
+		Algorithm:  string(ring.algorithm),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, nil
+}
+
+func (ring *signingKeyRing) install(stored *model.SigningKeyRing, now time.Time) error {
+	var active jose.JSONWebKey
+	published := make([]jose.JSONWebKey, 0, len(stored.Keys))
+	activeRecord := stored.ActiveKey(now)
+
+	for _, record := range stored.Keys {
+		data, err := ring.privateKeyPEM(record)
+		if err != nil {
+			return fmt.Errorf("stored signing key %s is unreadable: %w", record.KeyId, err)
+		}
+		key, err := parsePrivateKeyPEM(data)
+		if err != nil {
+			return fmt.Errorf("stored signing key %s is unreadable: %w", record.KeyId, err)
+		}
+		jwk := jose.JSONWebKey{Key: key, KeyID: record.KeyId, Algorithm: record.Algorithm, Use: "sig"}
+		if activeRecord != nil && record.KeyId == activeRecord.KeyId {
+			active = jwk
+		}
+		published = append(published, jwk.Public())
+	}
+
+	if active.Key == nil {
+		return errors.New("signing key ring has no active key")
+	}
+	signer, err := newTokenSigner(active)
+	if err != nil {
+		return err
+	}
+
+	var pending model.SigningKey
+	if newest := stored.NewestKey(); newest != nil && newest.IsPending(now) {
+		pending = model.SigningKey{KeyId: newest.KeyId, Algorithm: newest.Algorithm, ActivatesAt: newest.ActivatesAt}
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	ring.active = active
+	ring.signer = signer
+	ring.published = published
+	ring.pending = pending
+	return nil
+}
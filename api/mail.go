@@ -0,0 +1,115 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tournabyte/idp/mailer"
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	MAIL_DRIVER_SMTP    = "smtp"
+	MAIL_DRIVER_FILE    = "file"
+	MAIL_DRIVER_MAILDIR = "maildir"
+	MAIL_DRIVER_MEMORY  = "memory"
+	DEFAULT_SMTP_PORT   = 587
+
+	EMAIL_VERIFICATION_TEMPLATE = "email_verification"
+	PASSWORD_RESET_TEMPLATE     = "password_reset"
+)
+
+var errMailDisabled = errors.New("no mail driver is configured")
+
+// mailData is handed to every message template.
+type mailData struct {
+	Email       string
+	DisplayName string
+	Token       string
+	Link        string
+	ExpiresIn   string
+}
+
+func newMailer(opts *model.ApplicationOptions) (mailer.Mailer, error) {
+	switch opts.Mail.Driver {
+	case "":
+		return nil, nil
+	case MAIL_DRIVER_SMTP:
+		if opts.Mail.SMTP.Host == "" {
+			return nil, errors.New("mail.smtp.host is required by the smtp driver")
+		}
+		port := opts.Mail.SMTP.Port
+		if port == 0 {
+			port = DEFAULT_SMTP_PORT
+		}
+		return &mailer.SMTPMailer{
+			Host:     opts.Mail.SMTP.Host,
+			Port:     port,
+			Username: opts.Mail.SMTP.Username,
+			Password: opts.Mail.SMTP.Password,
+			StartTLS: opts.Mail.SMTP.StartTLS,
+		}, nil
+	case MAIL_DRIVER_FILE, MAIL_DRIVER_MAILDIR:
+		if opts.Mail.Directory == "" {
+			return nil, fmt.Errorf("mail.directory is required by the %s driver", opts.Mail.Driver)
+		}
+		return &mailer.FileMailer{Directory: opts.Mail.Directory, Maildir: opts.Mail.Driver == MAIL_DRIVER_MAILDIR}, nil
+	case MAIL_DRIVER_MEMORY:
+		return mailer.NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unsupported mail driver %q", opts.Mail.Driver)
+}
+
+func (provider *TournabyteIdentityProviderService) initializeMailer() error {
+	m, err := newMailer(provider.env)
+	if err != nil {
+		return err
+	}
+	if m != nil && provider.env.Mail.From == "" {
+		return errors.New("mail.from is required when a mail driver is configured")
+	}
+
+	templates := mailer.DefaultTemplates()
+	if provider.env.Mail.Templates != "" {
+		if templates, err = mailer.NewTemplates(os.DirFS(provider.env.Mail.Templates), "."); err != nil {
+			return fmt.Errorf("mail templates: %w", err)
+		}
+	}
+	provider.mailer = m
+	provider.mailTemplates = templates
+	return nil
+}
+
+// SetMailer replaces the configured mail driver, for instance with a mailer.MemoryMailer in tests.
+func (provider *TournabyteIdentityProviderService) SetMailer(m mailer.Mailer) {
+	provider.mailer = m
+}
+
+func (provider *TournabyteIdentityProviderService) sendMail(ctx context.Context, template string, acc *model.Account, data mailData) error {
+	if provider.mailer == nil {
+		return errMailDisabled
+	}
+	msg, err := provider.mailTemplates.Compose(template, provider.env.Mail.From, []string{acc.Email}, data)
+	if err != nil {
+		return err
+	}
+	return provider.mailer.Send(ctx, msg)
+}
+
+func formatLifetime(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+	return d.String()
+}
@@ -0,0 +1,518 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/model"
+	"github.com/tournabyte/idp/totp"
+)
+
+const (
+	MFA_CHALLENGE_PURPOSE          = "mfa_challenge"
+	MFA_METHOD_TOTP                = "totp"
+	DEFAULT_MFA_CHALLENGE_LIFETIME = 5 * time.Minute
+	DEFAULT_TOTP_ISSUER            = "Tournabyte"
+	DEFAULT_TOTP_SKEW              = 1
+)
+
+var (
+	errMFATokenInvalid = errors.New("mfa token is invalid")
+	errMFACodeInvalid  = errors.New("mfa code is invalid")
+)
+
+type mfaChallengeClaims struct {
+	jwt.Claims
+	Purpose string `json:"purpose"`
+}
+
+func (provider *TournabyteIdentityProviderService) mfaChallengeLifetime() time.Duration {
+	if provider.env.Accounts.MFA.ChallengeTTL > 0 {
+		return provider.env.Accounts.MFA.ChallengeTTL
+	}
+	return DEFAULT_MFA_CHALLENGE_LIFETIME
+}
+
+func (provider *TournabyteIdentityProviderService) totpIssuer() string {
+	if provider.env.Accounts.MFA.Issuer != "" {
+		return provider.env.Accounts.MFA.Issuer
+	}
+	return DEFAULT_TOTP_ISSUER
+}
+
+func (provider *TournabyteIdentityProviderService) totpSkew() int {
+	if provider.env.Accounts.MFA.Skew > 0 {
+		return provider.env.Accounts.MFA.Skew
+	}
+	return DEFAULT_TOTP_SKEW
+}
+
+// mfaChallengeAudience keeps challenge tokens from being accepted anywhere an access token is expected.
+func (provider *TournabyteIdentityProviderService) mfaChallengeAudience() jwt.Audience {
+	return jwt.Audience{provider.tokenIssuer() + "/accounts/mfa/challenge"}
+}
+
+func (provider *TournabyteIdentityProviderService) makeMFAChallengeToken(acc *model.Account) (string, error) {
+	cl := mfaChallengeClaims{
+		Claims: jwt.Claims{
+			Issuer:   provider.tokenIssuer(),
+			Subject:  acc.Id.Hex(),
+			Audience: provider.mfaChallengeAudience(),
+			Expiry:   jwt.NewNumericDate(time.Now().Add(provider.mfaChallengeLifetime())),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ID:       generateOpaqueToken(TOKEN_ID_BYTES),
+		},
+		Purpose: MFA_CHALLENGE_PURPOSE,
+	}
+	return jwt.Signed(provider.sessionTokenSigner).Claims(cl).Serialize()
+}
+
+func (provider *TournabyteIdentityProviderService) parseMFAChallengeToken(raw string) (*mfaChallengeClaims, error) {
+	var claims mfaChallengeClaims
+
+	if parseErr := provider.parseSignedToken(raw, &claims); parseErr != nil {
+		return nil, parseErr
+	}
+	expected := jwt.Expected{
+		Issuer:      provider.tokenIssuer(),
+		AnyAudience: provider.mfaChallengeAudience(),
+		Time:        time.Now(),
+	}
+	if validateErr := claims.ValidateWithLeeway(expected, provider.tokens.leeway); validateErr != nil {
+		return nil, validateErr
+	}
+	if claims.Purpose != MFA_CHALLENGE_PURPOSE || claims.Subject == "" || claims.Expiry == nil || claims.IssuedAt == nil {
+		return nil, errMFATokenInvalid
+	}
+	return &claims, nil
+}
+
+// verifySecondFactor checks a code of the account's authenticator or one of its recovery codes. Wrong and replayed
+// codes count as failed logins so that the lockout bounds how many codes can be guessed, and an accepted code
+// forgets the failures.
+func (provider *TournabyteIdentityProviderService) verifySecondFactor(ctx context.Context, accounts *model.TournabyteAccountRepository, acc *model.Account, code string) error {
+	if acc.IsLocked() {
+		return errAccountLocked
+	}
+
+	if isRecoveryCode(code) {
+		if redeemErr := provider.redeemRecoveryCode(ctx, accounts, acc, code); redeemErr != nil {
+			return redeemErr
+		}
+		accounts.ResetLoginAttempts(ctx, acc.Id)
+		return nil
+	}
+
+	step, ok := totp.Validate(acc.TOTPSecret, code, time.Now(), provider.totpSkew())
+	if !ok {
+		provider.recordFailedLogin(ctx, accounts, acc)
+		return errMFACodeInvalid
+	}
+	if consumeErr := accounts.ConsumeTOTPStep(ctx, acc.Id, step); errors.Is(consumeErr, model.ErrTOTPCodeReused) {
+		log.Printf("Refused a replayed code for account %s", acc.Id.Hex())
+		provider.recordFailedLogin(ctx, accounts, acc)
+		return errMFACodeInvalid
+	} else if consumeErr != nil {
+		return consumeErr
+	}
+
+	accounts.ResetLoginAttempts(ctx, acc.Id)
+	return nil
+}
+
+// challengeSecondFactor answers a correct password of an account with MFA by a short-lived token that is
+// exchanged for a session together with a code.
+func (provider *TournabyteIdentityProviderService) challengeSecondFactor(w http.ResponseWriter, r *http.Request, acc *model.Account) {
+	token, issueErr := provider.makeMFAChallengeToken(acc)
+	if issueErr != nil {
+		log.Printf("Could not issue an MFA challenge for account %s: %v", acc.Id.Hex(), issueErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "SESSION_NOT_CREATED", Message: "Could not establish a session"},
+			))
+		defer RecoverResponse(w, r)
+		panic("MFA challenge creation failed")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    token,
+				Methods:     provider.mfaMethods(acc),
+				ExpiresIn:   int64(provider.mfaChallengeLifetime().Seconds()),
+			},
+		))
+	EmitResponseAsJSON[model.MFAChallengeResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) completeMFAChallenge(w http.ResponseWriter, r *http.Request) {
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.MFAChallengeRequest)
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	var acc *model.Account
+	claims, challengeErr := provider.parseMFAChallengeToken(request.MFAToken)
+	if challengeErr == nil {
+		acc, challengeErr = accountsCollectionHandle.FindById(r.Context(), claims.Subject)
+	}
+	// A password change or revocation after the challenge was issued voids it.
+	if challengeErr == nil && (!acc.Active || !acc.MFAEnabled || acc.IssuedBeforeRevocation(claims.IssuedAt.Time())) {
+		challengeErr = errMFATokenInvalid
+	}
+	if challengeErr != nil {
+		log.Printf("Rejected MFA challenge token: %v", challengeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_TOKEN_INVALID", Message: "MFA token is invalid or expired, log in again"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid MFA challenge token")
+	}
+
+	switch verifyErr := provider.verifySecondFactor(r.Context(), accountsCollectionHandle, acc, request.Code); {
+	case errors.Is(verifyErr, errAccountLocked):
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("MFA challenge for a locked account")
+
+	case errors.Is(verifyErr, errMFACodeInvalid):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_CODE_INVALID", Message: "Code is invalid or was already used"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid MFA code")
+
+	case verifyErr != nil:
+		log.Printf("Could not verify the second factor of account %s: %v", acc.Id.Hex(), verifyErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "SESSION_NOT_CREATED", Message: "Could not establish a session"},
+			))
+		defer RecoverResponse(w, r)
+		panic("MFA verification failed")
+	}
+
+	provider.establishSession(w, r, acc)
+}
+
+func (provider *TournabyteIdentityProviderService) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	if principal == nil || principal.Subject != idHex || principal.Account == nil {
+		log.Printf("Principal may not enroll an authenticator for account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+	acc := principal.Account
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	secret, enrollErr := totp.GenerateSecret()
+	if enrollErr == nil {
+		enrollErr = accountsCollectionHandle.BeginTOTPEnrollment(r.Context(), acc.Id, secret)
+	}
+
+	switch {
+	case errors.Is(enrollErr, model.ErrMFAEnabled):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_ALREADY_ENABLED", Message: "Multi-factor authentication is already enabled"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Enrollment with MFA enabled")
+
+	case enrollErr != nil:
+		log.Printf("Could not begin the authenticator enrollment of account %s: %v", idHex, enrollErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_NOT_ENROLLED", Message: "Authenticator enrollment could not be started"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Enrollment failed")
+	}
+
+	log.Printf("Authenticator enrollment started for account %s", idHex)
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusCreated),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.TOTPEnrollmentResponse{Secret: secret, URI: totp.URI(provider.totpIssuer(), acc.Email, secret)},
+		))
+	EmitResponseAsJSON[model.TOTPEnrollmentResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) confirmTOTP(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.TOTPConfirmationRequest)
+	if principal == nil || principal.Subject != idHex || principal.Account == nil {
+		log.Printf("Principal may not confirm an authenticator for account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+	acc := principal.Account
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	var codes []string
+	confirmErr := model.ErrNoTOTPEnrollment
+	if acc.PendingTOTPSecret != "" {
+		if step, ok := totp.Validate(acc.PendingTOTPSecret, request.Code, time.Now(), provider.totpSkew()); ok {
+			var hashes []string
+			if codes, hashes, confirmErr = generateRecoveryCodes(); confirmErr == nil {
+				confirmErr = accountsCollectionHandle.ConfirmTOTPEnrollment(r.Context(), acc.Id, acc.PendingTOTPSecret, step, hashes)
+			}
+		} else {
+			confirmErr = errMFACodeInvalid
+		}
+	}
+
+	switch {
+	case errors.Is(confirmErr, model.ErrNoTOTPEnrollment):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_ENROLLMENT_NOT_STARTED", Message: "No authenticator enrollment is awaiting confirmation"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Confirmation without enrollment")
+
+	case errors.Is(confirmErr, errMFACodeInvalid):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_CODE_INVALID", Message: "Code does not match the enrolled authenticator"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid enrollment code")
+
+	case confirmErr != nil:
+		log.Printf("Could not confirm the authenticator of account %s: %v", idHex, confirmErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_NOT_ENROLLED", Message: "Authenticator could not be confirmed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Enrollment confirmation failed")
+	}
+
+	log.Printf("Multi-factor authentication enabled for account %s", idHex)
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.RecoveryCodesResponse{RecoveryCodes: codes},
+		))
+	EmitResponseAsJSON[model.RecoveryCodesResponse](w, r)
+}
+
+// disableMFA asks for both the password and a code so that a stolen session alone cannot strip the second factor.
+func (provider *TournabyteIdentityProviderService) disableMFA(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.MFADisableRequest)
+	if principal == nil || principal.Subject != idHex || principal.Account == nil {
+		log.Printf("Principal may not disable MFA of account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+	acc := principal.Account
+	if !acc.MFAEnabled {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_NOT_ENABLED", Message: "Multi-factor authentication is not enabled"},
+			))
+		defer RecoverResponse(w, r)
+		panic("MFA not enabled")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	checkErr := provider.checkCurrentPassword(r.Context(), accountsCollectionHandle, acc, request.CurrentPassword)
+	if checkErr == nil {
+		checkErr = provider.verifySecondFactor(r.Context(), accountsCollectionHandle, acc, request.Code)
+	}
+
+	switch {
+	case errors.Is(checkErr, errAccountLocked):
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Too many attempts at disabling MFA")
+
+	case errors.Is(checkErr, errInvalidCredentials):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "INVALID_CREDENTIALS", Message: "Current password does not match"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Current password mismatch")
+
+	case errors.Is(checkErr, errMFACodeInvalid):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_CODE_INVALID", Message: "Code is invalid or was already used"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid MFA code")
+	}
+
+	if checkErr == nil {
+		checkErr = accountsCollectionHandle.DisableMFA(r.Context(), acc.Id)
+	}
+	if checkErr != nil {
+		log.Printf("Could not disable MFA of account %s: %v", idHex, checkErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_NOT_DISABLED", Message: "Multi-factor authentication could not be disabled"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Disabling MFA failed")
+	}
+
+	log.Printf("Multi-factor authentication disabled for account %s", idHex)
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			struct{}{},
+		))
+	EmitResponseAsJSON[struct{}](w, r)
+}
@@ -0,0 +1,504 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	AUTHORIZATION_CODE_LIFETIME = 60 * time.Second
+	OAUTH_CLIENT_ID_BYTES       = 16
+	OAUTH_CLIENT_SECRET_BYTES   = 32
+	OAUTH_CODE_BYTES            = 32
+)
+
+var errInvalidClient = errors.New("client authentication failed")
+
+func (provider *TournabyteIdentityProviderService) RegisterClient(ctx context.Context, name string, redirectURIs []string, scopes []string, public bool) (*model.Client, string, error) {
+	clientsCollectionHandle := model.NewTournabyteClientRepository(
+		provider.collection("clients"),
+	)
+	client := model.Client{
+		Id:           generateOpaqueToken(OAUTH_CLIENT_ID_BYTES),
+		Name:         name,
+		Public:       public,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+	}
+
+	var secret string
+	if !public {
+		secret = generateOpaqueToken(OAUTH_CLIENT_SECRET_BYTES)
+		client.SecretHash = provider.mustHashPassword(secret)
+	}
+
+	if createErr := clientsCollectionHandle.Create(ctx, &client); createErr != nil {
+		return nil, "", createErr
+	}
+	return &client, secret, nil
+}
+
+func (provider *TournabyteIdentityProviderService) authenticateClient(ctx context.Context, r *http.Request, form url.Values) (*model.Client, error) {
+	clientsCollectionHandle := model.NewTournabyteClientRepository(
+		provider.collection("clients"),
+	)
+
+	clientId, clientSecret, basicAuth := r.BasicAuth()
+	if !basicAuth {
+		clientId = form.Get("client_id")
+		clientSecret = form.Get("client_secret")
+	}
+	if clientId == "" {
+		return nil, errInvalidClient
+	}
+
+	client, findErr := clientsCollectionHandle.FindById(ctx, clientId)
+	if findErr != nil {
+		log.Printf("No client found with id %s: %v", clientId, findErr)
+		return nil, errInvalidClient
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if match, err := argon2id.ComparePasswordAndHash(clientSecret, client.SecretHash); err != nil || !match {
+		log.Printf("Client secret did not match for client %s", clientId)
+		return nil, errInvalidClient
+	}
+	return client, nil
+}
+
+func redirectAuthorizationError(w http.ResponseWriter, r *http.Request, redirectURI string, oauthErr model.OAuthErrorResponse) {
+	target, _ := url.Parse(redirectURI)
+	query := target.Query()
+	query.Set("error", oauthErr.Error)
+	query.Set("error_description", oauthErr.Description)
+	if oauthErr.State != "" {
+		query.Set("state", oauthErr.State)
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Location", target.String())
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusFound),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			oauthErr,
+		))
+	defer RecoverResponse(w, r)
+	panic("Authorization request rejected")
+}
+
+func (provider *TournabyteIdentityProviderService) issueAuthorizationCode(w http.ResponseWriter, r *http.Request) {
+	params, _ := r.Context().Value(QUERY_VALUE_MAPPING).(map[string]string)
+	loginAttempt, ok := r.Context().Value(DECODED_JSON_BODY).(model.LoginAttempt)
+	if !ok {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "INVALID_JSON_BODY", Message: "Required body is not present or incorrectly structured"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid authorization attempt")
+	}
+
+	clientsCollectionHandle := model.NewTournabyteClientRepository(
+		provider.collection("clients"),
+	)
+	client, findErr := clientsCollectionHandle.FindById(r.Context(), params["client_id"])
+	if findErr != nil || !client.AllowsRedirectURI(params["redirect_uri"]) {
+		log.Printf("Authorization requested for unknown client or unregistered redirect URI: %s", params["client_id"])
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_request", Description: "Unknown client or unregistered redirect URI", State: params["state"]},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid authorization attempt")
+	}
+
+	if params["response_type"] != "code" {
+		redirectAuthorizationError(w, r, params["redirect_uri"], model.OAuthErrorResponse{
+			Error: "unsupported_response_type", Description: "Only the authorization code response type is supported", State: params["state"],
+		})
+		return
+	}
+
+	if params["code_challenge"] == "" || params["code_challenge_method"] != PKCE_METHOD_S256 {
+		redirectAuthorizationError(w, r, params["redirect_uri"], model.OAuthErrorResponse{
+			Error: "invalid_request", Description: "PKCE with the S256 challenge method is required", State: params["state"],
+		})
+		return
+	}
+
+	scopes := splitScope(params["scope"])
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !client.AllowsScopes(scopes) {
+		redirectAuthorizationError(w, r, params["redirect_uri"], model.OAuthErrorResponse{
+			Error: "invalid_scope", Description: "Requested scope exceeds the scope registered for the client", State: params["state"],
+		})
+		return
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	acc, authErr := provider.authenticateLoginAttempt(r.Context(), accountsCollectionHandle, loginAttempt)
+	if errors.Is(authErr, errAccountLocked) {
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Authorization attempt for a locked account")
+	}
+	if authErr != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "Invalid email or password"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid authorization attempt")
+	}
+
+	// There is no intermediate step in this flow, so accounts with MFA submit their code along with the password.
+	if acc.MFAEnabled {
+		if loginAttempt.MFACode == "" {
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "MFA_REQUIRED", Message: "A code from the enrolled authenticator is required"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Authorization attempt without a second factor")
+		}
+
+		switch verifyErr := provider.verifySecondFactor(r.Context(), accountsCollectionHandle, acc, loginAttempt.MFACode); {
+		case errors.Is(verifyErr, errAccountLocked):
+			setRetryAfter(w, acc.LockedUntil)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Authorization attempt for a locked account")
+
+		case verifyErr != nil:
+			log.Printf("Second factor of account %s not verified: %v", acc.Id.Hex(), verifyErr)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "MFA_CODE_INVALID", Message: "Code is invalid or was already used"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Invalid MFA code")
+		}
+	}
+
+	code := generateOpaqueToken(OAUTH_CODE_BYTES)
+	codesCollectionHandle := model.NewTournabyteAuthorizationCodeRepository(
+		provider.collection("authorization_codes"),
+	)
+	codeRecord := model.AuthorizationCode{
+		CodeHash:            hashOpaqueToken(code),
+		ClientId:            client.Id,
+		AccountId:           acc.Id,
+		RedirectURI:         params["redirect_uri"],
+		Scope:               strings.Join(scopes, " "),
+		CodeChallenge:       params["code_challenge"],
+		CodeChallengeMethod: params["code_challenge_method"],
+		Nonce:               params["nonce"],
+	}
+	if createErr := codesCollectionHandle.Create(r.Context(), &codeRecord, AUTHORIZATION_CODE_LIFETIME); createErr != nil {
+		log.Printf("Did not persist the authorization code: %v", createErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Authorization code could not be issued", State: params["state"]},
+			))
+		defer RecoverResponse(w, r)
+		panic("Authorization code creation failed")
+	}
+
+	target, _ := url.Parse(params["redirect_uri"])
+	query := target.Query()
+	query.Set("code", code)
+	if params["state"] != "" {
+		query.Set("state", params["state"])
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Location", target.String())
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusFound),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.AuthorizationCodeResponse{Code: code, State: params["state"], RedirectURI: target.String()},
+		))
+	EmitResponseAsJSON[model.AuthorizationCodeResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) exchangeToken(w http.ResponseWriter, r *http.Request) {
+	form, ok := r.Context().Value(DECODED_FORM_BODY).(url.Values)
+	if !ok {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_request", Description: "Required form body is not present"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+
+	var client *model.Client
+	var clientErr error
+	_, _, basicAuth := r.BasicAuth()
+	if basicAuth || form.Get("client_id") != "" || form.Get("grant_type") != "refresh_token" {
+		client, clientErr = provider.authenticateClient(r.Context(), r, form)
+	}
+	if clientErr != nil {
+		if basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_client", Description: "Client authentication failed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+
+	switch form.Get("grant_type") {
+	case "authorization_code":
+		provider.exchangeAuthorizationCode(w, r, client, form)
+	case "refresh_token":
+		provider.exchangeRefreshToken(w, r, client, form)
+	default:
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "unsupported_grant_type", Description: "The grant type is not supported"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client *model.Client, form url.Values) {
+	codesCollectionHandle := model.NewTournabyteAuthorizationCodeRepository(
+		provider.collection("authorization_codes"),
+	)
+	code, consumeErr := codesCollectionHandle.Consume(r.Context(), hashOpaqueToken(form.Get("code")))
+
+	switch {
+	case errors.Is(consumeErr, mongo.ErrNoDocuments):
+		log.Printf("Authorization code is unknown, expired or already used")
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Authorization code is invalid or expired"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+
+	case consumeErr != nil:
+		log.Printf("Failed to consume the authorization code: %v", consumeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Authorization code could not be redeemed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token request failed")
+
+	case code.ClientId != client.Id || code.RedirectURI != form.Get("redirect_uri"):
+		log.Printf("Authorization code presented by client %s was issued to %s", client.Id, code.ClientId)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Authorization code was not issued for this client or redirect URI"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+
+	case !verifyCodeChallenge(form.Get("code_verifier"), code.CodeChallenge, code.CodeChallengeMethod):
+		log.Printf("PKCE verification failed for client %s", client.Id)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Code verifier does not match the code challenge"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	acc, findErr := accountsCollectionHandle.FindById(r.Context(), code.AccountId.Hex())
+	if findErr != nil && !errors.Is(findErr, mongo.ErrNoDocuments) {
+		log.Printf("Failed to look up the account of the authorization code: %v", findErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Token request could not be processed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token request failed")
+	}
+	if findErr != nil {
+		log.Printf("Account for authorization code is no longer available: %v", findErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Authorizing account is no longer active"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+
+	response, issueErr := provider.makeTokenResponse(r.Context(), acc, client.Id, code.Scope, code.Nonce, code.CreatedAt)
+	if issueErr == nil {
+		response.RefreshToken, issueErr = provider.issueRefreshToken(r.Context(), acc.Id, client.Id, code.Scope, code.CreatedAt, "")
+	}
+	if issueErr != nil {
+		log.Printf("Could not issue tokens: %v", issueErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Tokens could not be issued"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token creation failed")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			response,
+		))
+	EmitResponseAsJSON[model.TokenResponse](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) makeTokenResponse(ctx context.Context, acc *model.Account, clientId string, scope string, nonce string, authTime time.Time) (model.TokenResponse, error) {
+	response := model.TokenResponse{
+		TokenType: "Bearer",
+		ExpiresIn: int64(provider.tokens.accessTokenLifetime.Seconds()),
+		Scope:     scope,
+	}
+
+	var err error
+	if response.AccessToken, err = provider.makeAccessToken(ctx, acc, clientId, scope); err != nil {
+		return response, err
+	}
+	if clientId != "" && slices.Contains(splitScope(scope), SCOPE_OPENID) {
+		if response.IdToken, err = provider.makeIdToken(ctx, acc, clientId, scope, nonce, authTime); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,361 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/tournabyte/idp/model"
+	"github.com/tournabyte/idp/passwordpolicy"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	PASSWORD_RESET_TOKEN_LIFETIME = 1 * time.Hour
+	PASSWORD_RESET_TOKEN_BYTES    = 32
+)
+
+func (provider *TournabyteIdentityProviderService) initializePasswordPolicy() error {
+	opts := provider.env.Accounts.Password
+	policy := passwordpolicy.Policy{
+		MinLength:         opts.MinLength,
+		MaxLength:         opts.MaxLength,
+		RequireLowercase:  opts.RequireLowercase,
+		RequireUppercase:  opts.RequireUppercase,
+		RequireDigit:      opts.RequireDigit,
+		RequireSymbol:     opts.RequireSymbol,
+		AllowEmailDerived: opts.AllowEmailDerived,
+	}
+	if opts.BreachedList != "" {
+		corpus, err := passwordpolicy.OpenBreachedPasswords(opts.BreachedList)
+		if err != nil {
+			return fmt.Errorf("breached password list: %w", err)
+		}
+		policy.Breached = corpus
+	}
+	provider.passwordPolicy = &policy
+	return nil
+}
+
+// rejectPassword reports why a password cannot be used by the account with the given email, or nil when the policy accepts it.
+func (provider *TournabyteIdentityProviderService) rejectPassword(ctx context.Context, password string, email string) *requestRejection {
+	violations, err := provider.passwordPolicy.Check(ctx, password, email)
+	if err != nil {
+		log.Printf("Could not check the password policy: %v", err)
+		return &requestRejection{http.StatusInternalServerError, model.ErrorResponse{Reason: "PASSWORD_NOT_CHECKED", Message: "Password could not be checked against the policy"}}
+	}
+	if len(violations) > 0 {
+		return &requestRejection{http.StatusBadRequest, model.ErrorResponse{Reason: "PASSWORD_POLICY_VIOLATION", Message: "Password does not satisfy the password policy", Violations: violations}}
+	}
+	return nil
+}
+
+// revokeAccountSessions revokes every refresh token of the account; access tokens are cut off by the
+// sessions_valid_after timestamp written alongside the change that warranted the revocation.
+func (provider *TournabyteIdentityProviderService) revokeAccountSessions(ctx context.Context, accountId bson.ObjectID) error {
+	refreshTokensCollectionHandle := model.NewTournabyteRefreshTokenRepository(
+		provider.collection("refresh_tokens"),
+	)
+	return refreshTokensCollectionHandle.RevokeAccount(ctx, accountId)
+}
+
+// awaitRevocationCutoff blocks until the next second starts. Token timestamps have second precision and tokens from
+// the same second as sessions_valid_after count as revoked, so fresh tokens must not be issued before then.
+func awaitRevocationCutoff(ctx context.Context) error {
+	timer := time.NewTimer(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) deliverPasswordReset(ctx context.Context, acc *model.Account, token string) error {
+	return provider.sendMail(ctx, PASSWORD_RESET_TEMPLATE, acc, mailData{
+		Email:       acc.Email,
+		DisplayName: acc.DisplayName,
+		Token:       token,
+		Link:        provider.tokenIssuer() + "/accounts/password-reset/confirm",
+		ExpiresIn:   formatLifetime(PASSWORD_RESET_TOKEN_LIFETIME),
+	})
+}
+
+// requestPasswordReset answers identically whether or not the email belongs to an account so it cannot be used for enumeration.
+func (provider *TournabyteIdentityProviderService) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.PasswordResetRequest)
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	if acc, findErr := accountsCollectionHandle.FindByEmail(r.Context(), request.Email); findErr == nil && acc.Active {
+		token := generateOpaqueToken(PASSWORD_RESET_TOKEN_BYTES)
+		resetsCollectionHandle := model.NewTournabytePasswordResetRepository(
+			provider.collection("password_resets"),
+		)
+		record := model.PasswordResetToken{TokenHash: hashOpaqueToken(token), AccountId: acc.Id, Email: acc.Email}
+		if createErr := resetsCollectionHandle.Create(r.Context(), &record, PASSWORD_RESET_TOKEN_LIFETIME); createErr != nil {
+			log.Printf("Did not persist the password reset token for account %s: %v", acc.Id.Hex(), createErr)
+		} else if deliverErr := provider.deliverPasswordReset(r.Context(), acc, token); deliverErr != nil {
+			log.Printf("Could not deliver the password reset token for account %s: %v", acc.Id.Hex(), deliverErr)
+		}
+	}
+
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusAccepted),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			struct{}{},
+		))
+	EmitResponseAsJSON[struct{}](w, r)
+}
+
+func (provider *TournabyteIdentityProviderService) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	confirmation, _ := r.Context().Value(DECODED_JSON_BODY).(model.PasswordResetConfirmation)
+
+	resetsCollectionHandle := model.NewTournabytePasswordResetRepository(
+		provider.collection("password_resets"),
+	)
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	// The token is only consumed once the new password is accepted so that a rejected password does not burn it.
+	reset, resetErr := resetsCollectionHandle.Find(r.Context(), hashOpaqueToken(confirmation.Token))
+	var acc *model.Account
+	if resetErr == nil {
+		acc, resetErr = accountsCollectionHandle.FindById(r.Context(), reset.AccountId.Hex())
+		if resetErr == nil && acc.Email != reset.Email {
+			resetErr = mongo.ErrNoDocuments
+		}
+	}
+	if resetErr == nil {
+		if rejection := provider.rejectPassword(r.Context(), confirmation.NewPassword, acc.Email); rejection != nil {
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, rejection.status),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					rejection.response,
+				))
+			defer RecoverResponse(w, r)
+			panic("Password rejected")
+		}
+		_, resetErr = resetsCollectionHandle.Consume(r.Context(), reset.TokenHash)
+	}
+	if resetErr == nil {
+		resetErr = accountsCollectionHandle.SetLoginKey(r.Context(), acc.Id, provider.mustHashPassword(confirmation.NewPassword), true)
+	}
+	if resetErr == nil {
+		resetErr = provider.revokeAccountSessions(r.Context(), acc.Id)
+	}
+
+	switch {
+	case errors.Is(resetErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "RESET_TOKEN_INVALID", Message: "Reset token is invalid, expired or already used"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid password reset token")
+
+	case resetErr != nil:
+		log.Printf("Failed to reset the password: %v", resetErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PASSWORD_NOT_RESET", Message: "Password could not be reset"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Password reset failed")
+
+	default:
+		log.Printf("Password of account %s reset and its sessions revoked", acc.Id.Hex())
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				struct{}{},
+			))
+		EmitResponseAsJSON[struct{}](w, r)
+	}
+}
+
+// checkCurrentPassword counts a mismatch as a failed login attempt so the endpoint cannot be used to guess passwords.
+func (provider *TournabyteIdentityProviderService) checkCurrentPassword(ctx context.Context, accounts *model.TournabyteAccountRepository, acc *model.Account, password string) error {
+	if acc.IsLocked() {
+		return errAccountLocked
+	}
+	if match, err := argon2id.ComparePasswordAndHash(password, acc.LoginKey); err != nil || !match {
+		provider.recordFailedLogin(ctx, accounts, acc)
+		return errInvalidCredentials
+	}
+	return nil
+}
+
+func (provider *TournabyteIdentityProviderService) changePassword(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.PasswordChangeRequest)
+	if principal == nil || principal.Subject != idHex || principal.Account == nil {
+		log.Printf("Principal may not change the password of account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+	acc := principal.Account
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	switch checkErr := provider.checkCurrentPassword(r.Context(), accountsCollectionHandle, acc, request.CurrentPassword); {
+	case errors.Is(checkErr, errAccountLocked):
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Too many attempts at changing the password")
+
+	case checkErr != nil:
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "INVALID_CREDENTIALS", Message: "Current password does not match"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Current password mismatch")
+	}
+
+	if rejection := provider.rejectPassword(r.Context(), request.NewPassword, acc.Email); rejection != nil {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, rejection.status),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				rejection.response,
+			))
+		defer RecoverResponse(w, r)
+		panic("Password rejected")
+	}
+
+	changeErr := accountsCollectionHandle.SetLoginKey(r.Context(), acc.Id, provider.mustHashPassword(request.NewPassword), request.RevokeOtherSessions)
+	if changeErr == nil && request.RevokeOtherSessions {
+		changeErr = provider.revokeAccountSessions(r.Context(), acc.Id)
+	}
+	if changeErr != nil {
+		log.Printf("Failed to change the password of account %s: %v", idHex, changeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "PASSWORD_NOT_CHANGED", Message: "Password could not be changed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Password change failed")
+	}
+
+	if !request.RevokeOtherSessions {
+		log.Printf("Password of account %s changed", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				struct{}{},
+			))
+		EmitResponseAsJSON[struct{}](w, r)
+		return
+	}
+
+	// Revocation also cuts off the token presented with this request, so the caller continues on fresh tokens. A
+	// first-party session carries no client id and gets its refresh token the same way establishSession issues it.
+	var response model.TokenResponse
+	scope := strings.Join(principal.Scopes, " ")
+	issueErr := awaitRevocationCutoff(r.Context())
+	if issueErr == nil {
+		response, issueErr = provider.makeTokenResponse(r.Context(), acc, principal.ClientId, scope, "", time.Now())
+	}
+	if issueErr == nil {
+		response.RefreshToken, issueErr = provider.issueRefreshToken(r.Context(), acc.Id, principal.ClientId, scope, time.Now(), "")
+	}
+	if issueErr != nil {
+		log.Printf("Password of account %s changed but no tokens could be issued: %v", idHex, issueErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "TOKEN_NOT_ISSUED", Message: "Password changed but the session could not be renewed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token creation failed")
+	}
+
+	log.Printf("Password of account %s changed and its other sessions revoked", idHex)
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			response,
+		))
+	EmitResponseAsJSON[model.TokenResponse](w, r)
+}
@@ -0,0 +1,90 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/tournabyte/idp/model"
+	"github.com/tournabyte/idp/passwordpolicy"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type ChangePasswordTestSuite struct {
+	suite.Suite
+	accounts      *MockCollectionHandle
+	refreshTokens *MockCollectionHandle
+	provider      *TournabyteIdentityProviderService
+	account       *model.Account
+}
+
+func TestChangePassword(t *testing.T) {
+	suite.Run(t, new(ChangePasswordTestSuite))
+}
+
+func (s *ChangePasswordTestSuite) SetupTest() {
+	key, err := newSymmetricSigningKey(strings.Repeat("k", HMAC_MIN_SECRET_SIZE))
+	s.Require().NoError(err)
+	signer, err := newTokenSigner(key)
+	s.Require().NoError(err)
+	hash, err := argon2id.CreateHash("old-password", argon2id.DefaultParams)
+	s.Require().NoError(err)
+
+	s.accounts = new(MockCollectionHandle)
+	s.refreshTokens = new(MockCollectionHandle)
+	s.provider = &TournabyteIdentityProviderService{
+		env:                &model.ApplicationOptions{},
+		sessionTokenSigner: signer,
+		tokens:             &tokenSettings{issuer: "https://idp.test", audience: "tournabyte", accessTokenLifetime: time.Minute, refreshTokenLifetime: time.Hour},
+		passwordPolicy:     &passwordpolicy.Policy{},
+		collections:        mockCollections(map[string]*MockCollectionHandle{"accounts": s.accounts, "refresh_tokens": s.refreshTokens}),
+	}
+	s.account = &model.Account{Id: bson.NewObjectID(), Email: "player@example.com", LoginKey: hash, Active: true}
+}
+
+func (s *ChangePasswordTestSuite) change(principal *AuthenticatedPrincipal, request model.PasswordChangeRequest) (*httptest.ResponseRecorder, model.TokenResponse) {
+	idHex := s.account.Id.Hex()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/accounts/"+idHex+"/password", nil)
+	r = r.WithContext(context.WithValue(r.Context(), PATH_VALUE_MAPPING, map[string]string{"id": idHex}))
+	r = r.WithContext(context.WithValue(r.Context(), AUTHENTICATED_PRINCIPAL, principal))
+	r = r.WithContext(context.WithValue(r.Context(), DECODED_JSON_BODY, request))
+	s.provider.changePassword(w, r)
+
+	var body model.TokenResponse
+	json.NewDecoder(w.Body).Decode(&body)
+	return w, body
+}
+
+func (s *ChangePasswordTestSuite) TestFirstPartySessionIsRenewedWithRefreshToken() {
+	s.accounts.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	s.refreshTokens.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.refreshTokens.On("InsertOne", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+		return token.AccountId == s.account.Id && token.ClientId == "" && token.Scope == SESSION_TOKEN_SCOPE
+	})).Return(&mongo.InsertOneResult{}, nil)
+
+	principal := &AuthenticatedPrincipal{Subject: s.account.Id.Hex(), Scopes: splitScope(SESSION_TOKEN_SCOPE), Account: s.account}
+	w, body := s.change(principal, model.PasswordChangeRequest{
+		CurrentPassword:     "old-password",
+		NewPassword:         "a-much-longer-new-password",
+		RevokeOtherSessions: true,
+	})
+
+	assert.Equal(s.T(), http.StatusOK, w.Code)
+	assert.NotEmpty(s.T(), body.AccessToken)
+	assert.NotEmpty(s.T(), body.RefreshToken)
+	s.refreshTokens.AssertCalled(s.T(), "UpdateMany", mock.Anything, mock.Anything, mock.Anything)
+	s.refreshTokens.AssertNumberOfCalls(s.T(), "InsertOne", 1)
+}
@@ -0,0 +1,187 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tournabyte/idp/model"
+	"github.com/tournabyte/idp/ratelimit"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	RATE_LIMIT_STORE_MEMORY = "memory"
+	RATE_LIMIT_STORE_MONGO  = "mongo"
+
+	DEFAULT_PER_IP_REQUESTS    = 20
+	DEFAULT_PER_EMAIL_REQUESTS = 5
+	DEFAULT_RATE_LIMIT_PERIOD  = 1 * time.Minute
+)
+
+func withDefaultLimit(requests int, period time.Duration, defaultRequests int) ratelimit.Limit {
+	limit := ratelimit.Limit{Requests: requests, Period: period}
+	if limit.Requests <= 0 {
+		limit.Requests = defaultRequests
+	}
+	if limit.Period <= 0 {
+		limit.Period = DEFAULT_RATE_LIMIT_PERIOD
+	}
+	return limit
+}
+
+// initializeRateLimits needs the database connection when buckets are shared through mongo.
+func (provider *TournabyteIdentityProviderService) initializeRateLimits() error {
+	opts := provider.env.RateLimit
+	if opts.Disabled {
+		log.Printf("Rate limiting is disabled")
+		return nil
+	}
+
+	resolver, err := ratelimit.NewClientIPResolver(opts.TrustedProxies, opts.ProxyHeaders)
+	if err != nil {
+		return err
+	}
+
+	var store ratelimit.Store
+	switch opts.Store {
+	case "", RATE_LIMIT_STORE_MEMORY:
+		store = ratelimit.NewMemoryStore()
+	case RATE_LIMIT_STORE_MONGO:
+		store = model.NewTournabyteRateLimitRepository(provider.collection("rate_limits"))
+	default:
+		return fmt.Errorf("unsupported rate limit store %q", opts.Store)
+	}
+
+	provider.clientIPs = resolver
+	provider.rateLimitStore = store
+	provider.perIPLimit = withDefaultLimit(opts.PerIP.Requests, opts.PerIP.Period, DEFAULT_PER_IP_REQUESTS)
+	provider.perEmailLimit = withDefaultLimit(opts.PerEmail.Requests, opts.PerEmail.Period, DEFAULT_PER_EMAIL_REQUESTS)
+	return nil
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, limit ratelimit.Limit, decision ratelimit.Decision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(decision.Reset.Seconds())), 10))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int64(limit.Period.Seconds())))
+}
+
+// rateLimit spends a token from the bucket under key and rejects the request when it is empty. The store failing
+// must not take authentication down with it, so such requests are let through.
+func (provider *TournabyteIdentityProviderService) rateLimit(w http.ResponseWriter, r *http.Request, next http.HandlerFunc, limit ratelimit.Limit, key string) {
+	decision, err := provider.rateLimitStore.Take(r.Context(), key, limit, time.Now())
+	if err != nil {
+		log.Printf("Could not consult the rate limit for %s, letting the request through: %v", key, err)
+		next(w, r)
+		return
+	}
+
+	setRateLimitHeaders(w, limit, decision)
+	if !decision.Allowed {
+		log.Printf("Rate limit exceeded for %s", key)
+		w.Header().Set("Retry-After", strconv.FormatInt(max(int64(math.Ceil(decision.Reset.Seconds())), 1), 10))
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "RATE_LIMITED", Message: "Too many requests, try again later"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Rate limit exceeded")
+	}
+	next(w, r)
+}
+
+// LimitPerClientIP throttles the routes sharing scope by the address of the client.
+func (provider *TournabyteIdentityProviderService) LimitPerClientIP(scope string) HandlerFuncProcessingStep {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if provider.rateLimitStore == nil {
+				next(w, r)
+				return
+			}
+			provider.rateLimit(w, r, next, provider.perIPLimit, scope+":ip:"+provider.clientIPs.ClientIP(r))
+		}
+	}
+}
+
+// LimitPerEmail throttles the routes sharing scope by the email submitted in the decoded request body, so it
+// must run after ReadRequestBodyAsJSON. Requests without a usable email are left to the per-IP limit.
+func (provider *TournabyteIdentityProviderService) LimitPerEmail(scope string, email func(*http.Request) string) HandlerFuncProcessingStep {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if provider.rateLimitStore == nil {
+				next(w, r)
+				return
+			}
+			normalized, err := model.NormalizeEmail(email(r))
+			if err != nil {
+				next(w, r)
+				return
+			}
+			provider.rateLimit(w, r, next, provider.perEmailLimit, scope+":email:"+normalized)
+		}
+	}
+}
+
+// LimitPerAccount throttles the routes sharing scope by the account they act on, drawing from buckets sized like the
+// per-email ones since both bound what a single mailbox receives. Requests without a valid account id are left to the
+// per-IP limit.
+func (provider *TournabyteIdentityProviderService) LimitPerAccount(scope string, account func(*http.Request) string) HandlerFuncProcessingStep {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if provider.rateLimitStore == nil {
+				next(w, r)
+				return
+			}
+			oid, err := bson.ObjectIDFromHex(account(r))
+			if err != nil {
+				next(w, r)
+				return
+			}
+			provider.rateLimit(w, r, next, provider.perEmailLimit, scope+":account:"+oid.Hex())
+		}
+	}
+}
+
+func pathAccountId(r *http.Request) string {
+	params, _ := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)
+	return params["id"]
+}
+
+// mfaChallengeAccount is the subject of a valid MFA challenge token, so that guessing codes is throttled per account
+// before any of them is hashed.
+func (provider *TournabyteIdentityProviderService) mfaChallengeAccount(r *http.Request) string {
+	req, _ := r.Context().Value(DECODED_JSON_BODY).(model.MFAChallengeRequest)
+	claims, err := provider.parseMFAChallengeToken(req.MFAToken)
+	if err != nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+func loginAttemptEmail(r *http.Request) string {
+	attempt, _ := r.Context().Value(DECODED_JSON_BODY).(model.LoginAttempt)
+	return attempt.LoginId
+}
+
+func newAccountEmail(r *http.Request) string {
+	req, _ := r.Context().Value(DECODED_JSON_BODY).(model.CreateAccountRequest)
+	return req.NewAccountEmail
+}
+
+func passwordResetEmail(r *http.Request) string {
+	req, _ := r.Context().Value(DECODED_JSON_BODY).(model.PasswordResetRequest)
+	return req.Email
+}
@@ -0,0 +1,204 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	RECOVERY_CODE_COUNT    = 10
+	RECOVERY_CODE_LENGTH   = 10
+	RECOVERY_CODE_ALPHABET = "0123456789abcdefghjkmnpqrstvwxyz"
+	MFA_METHOD_RECOVERY    = "recovery_code"
+
+	// Codes used to be stored behind their leading symbols in the clear; the separator is kept to read those entries.
+	RECOVERY_CODE_LOOKUP_SEPARATOR = ":"
+)
+
+// normalizeRecoveryCode lets players type codes without the separator and in any case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func isRecoveryCode(code string) bool {
+	return len(normalizeRecoveryCode(code)) == RECOVERY_CODE_LENGTH
+}
+
+// generateRecoveryCodes returns codes to show the player once and the argon2id hashes to store in their place.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RECOVERY_CODE_COUNT)
+	hashes := make([]string, 0, RECOVERY_CODE_COUNT)
+
+	for range RECOVERY_CODE_COUNT {
+		buf := make([]byte, RECOVERY_CODE_LENGTH)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		// The alphabet holds 32 symbols, so masking keeps every symbol equally likely.
+		for i := range buf {
+			buf[i] = RECOVERY_CODE_ALPHABET[buf[i]&0x1f]
+		}
+
+		hash, err := argon2id.CreateHash(string(buf), argon2id.DefaultParams)
+		if err != nil {
+			return nil, nil, err
+		}
+		half := RECOVERY_CODE_LENGTH / 2
+		codes = append(codes, string(buf[:half])+"-"+string(buf[half:]))
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+// redeemRecoveryCode spends the recovery code of the account that matches. Wrong and spent codes count as failed
+// logins like wrong authenticator codes do. Every remaining code is compared, which the per-account challenge limit
+// keeps to a handful of hashes per attempt.
+func (provider *TournabyteIdentityProviderService) redeemRecoveryCode(ctx context.Context, accounts *model.TournabyteAccountRepository, acc *model.Account, code string) error {
+	code = normalizeRecoveryCode(code)
+	for _, stored := range acc.RecoveryCodes {
+		hash := stored
+		if _, unprefixed, hasLookup := strings.Cut(stored, RECOVERY_CODE_LOOKUP_SEPARATOR); hasLookup {
+			hash = unprefixed
+		}
+		if match, err := argon2id.ComparePasswordAndHash(code, hash); err != nil || !match {
+			continue
+		}
+
+		remaining, consumeErr := accounts.ConsumeRecoveryCode(ctx, acc.Id, stored)
+		if errors.Is(consumeErr, model.ErrRecoveryCodeUsed) {
+			break
+		} else if consumeErr != nil {
+			return consumeErr
+		}
+		log.Printf("Account %s used a recovery code, %d remain", acc.Id.Hex(), remaining)
+		return nil
+	}
+
+	provider.recordFailedLogin(ctx, accounts, acc)
+	return errMFACodeInvalid
+}
+
+func (provider *TournabyteIdentityProviderService) mfaMethods(acc *model.Account) []string {
+	if acc.RemainingRecoveryCodes() > 0 {
+		return []string{MFA_METHOD_TOTP, MFA_METHOD_RECOVERY}
+	}
+	return []string{MFA_METHOD_TOTP}
+}
+
+// regenerateRecoveryCodes voids the remaining recovery codes of the account and issues a new set. The password is
+// asked for so that a stolen session alone cannot obtain codes that get past the second factor.
+func (provider *TournabyteIdentityProviderService) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.RecoveryCodesRequest)
+	if principal == nil || principal.Subject != idHex || principal.Account == nil {
+		log.Printf("Principal may not regenerate recovery codes of account %s", idHex)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Principal not permitted")
+	}
+	acc := principal.Account
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	regenerateErr := model.ErrMFANotEnabled
+	if acc.MFAEnabled {
+		regenerateErr = provider.checkCurrentPassword(r.Context(), accountsCollectionHandle, acc, request.CurrentPassword)
+	}
+
+	var codes []string
+	if regenerateErr == nil {
+		var hashes []string
+		if codes, hashes, regenerateErr = generateRecoveryCodes(); regenerateErr == nil {
+			regenerateErr = accountsCollectionHandle.SetRecoveryCodes(r.Context(), acc.Id, hashes)
+		}
+	}
+
+	switch {
+	case errors.Is(regenerateErr, model.ErrMFANotEnabled):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "MFA_NOT_ENABLED", Message: "Multi-factor authentication is not enabled"},
+			))
+		defer RecoverResponse(w, r)
+		panic("MFA not enabled")
+
+	case errors.Is(regenerateErr, errAccountLocked):
+		setRetryAfter(w, acc.LockedUntil)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Too many attempts at regenerating recovery codes")
+
+	case errors.Is(regenerateErr, errInvalidCredentials):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "INVALID_CREDENTIALS", Message: "Current password does not match"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Current password mismatch")
+
+	case regenerateErr != nil:
+		log.Printf("Could not regenerate the recovery codes of account %s: %v", idHex, regenerateErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "RECOVERY_CODES_NOT_ISSUED", Message: "Recovery codes could not be regenerated"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Recovery code regeneration failed")
+	}
+
+	log.Printf("Recovery codes of account %s regenerated", idHex)
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusCreated),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.RecoveryCodesResponse{RecoveryCodes: codes},
+		))
+	EmitResponseAsJSON[model.RecoveryCodesResponse](w, r)
+}
@@ -0,0 +1,183 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	REFRESH_TOKEN_BYTES = 32
+	TOKEN_FAMILY_BYTES  = 16
+)
+
+func (provider *TournabyteIdentityProviderService) issueRefreshToken(ctx context.Context, accountId bson.ObjectID, clientId string, scope string, authTime time.Time, familyId string) (string, error) {
+	refreshTokensCollectionHandle := model.NewTournabyteRefreshTokenRepository(
+		provider.collection("refresh_tokens"),
+	)
+	if familyId == "" {
+		familyId = generateOpaqueToken(TOKEN_FAMILY_BYTES)
+	}
+
+	token := generateOpaqueToken(REFRESH_TOKEN_BYTES)
+	record := model.RefreshToken{
+		TokenHash: hashOpaqueToken(token),
+		FamilyId:  familyId,
+		AccountId: accountId,
+		ClientId:  clientId,
+		Scope:     scope,
+		AuthTime:  authTime.UTC(),
+	}
+	if createErr := refreshTokensCollectionHandle.Create(ctx, &record, provider.tokens.refreshTokenLifetime); createErr != nil {
+		return "", createErr
+	}
+	return token, nil
+}
+
+func (provider *TournabyteIdentityProviderService) exchangeRefreshToken(w http.ResponseWriter, r *http.Request, client *model.Client, form url.Values) {
+	refreshTokensCollectionHandle := model.NewTournabyteRefreshTokenRepository(
+		provider.collection("refresh_tokens"),
+	)
+	var clientId string
+	if client != nil {
+		clientId = client.Id
+	}
+
+	previous, redeemErr := refreshTokensCollectionHandle.Redeem(r.Context(), hashOpaqueToken(form.Get("refresh_token")), clientId)
+
+	switch {
+	case errors.Is(redeemErr, model.ErrRefreshTokenReplayed):
+		log.Printf("Refresh token replay detected, revoking token family %s", previous.FamilyId)
+		if revokeErr := refreshTokensCollectionHandle.RevokeFamily(r.Context(), previous.FamilyId); revokeErr != nil {
+			log.Printf("Failed to revoke refresh token family %s: %v", previous.FamilyId, revokeErr)
+		}
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Refresh token is invalid or expired"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Refresh token replayed")
+
+	case errors.Is(redeemErr, mongo.ErrNoDocuments):
+		log.Printf("Refresh token is unknown, expired or revoked")
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Refresh token is invalid or expired"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+
+	case errors.Is(redeemErr, model.ErrRefreshTokenClientMismatch):
+		log.Printf("Refresh token presented by client %q was issued to %q", clientId, previous.ClientId)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Refresh token was not issued to this client"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+
+	case redeemErr != nil:
+		log.Printf("Failed to redeem the refresh token: %v", redeemErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Refresh token could not be redeemed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token request failed")
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	acc, findErr := accountsCollectionHandle.FindById(r.Context(), previous.AccountId.Hex())
+	if findErr != nil && !errors.Is(findErr, mongo.ErrNoDocuments) {
+		log.Printf("Failed to look up the account of the refresh token: %v", findErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Token request could not be processed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token request failed")
+	}
+	if findErr != nil {
+		log.Printf("Account for refresh token is no longer available: %v", findErr)
+		refreshTokensCollectionHandle.RevokeFamily(r.Context(), previous.FamilyId)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_grant", Description: "Authorizing account is no longer active"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid token request")
+	}
+
+	response, issueErr := provider.makeTokenResponse(r.Context(), acc, previous.ClientId, previous.Scope, "", previous.AuthTime)
+	if issueErr == nil {
+		response.RefreshToken, issueErr = provider.issueRefreshToken(r.Context(), acc.Id, previous.ClientId, previous.Scope, previous.AuthTime, previous.FamilyId)
+	}
+	if issueErr != nil {
+		log.Printf("Could not issue tokens for the rotated refresh token: %v", issueErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "server_error", Description: "Tokens could not be issued"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token creation failed")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			response,
+		))
+	EmitResponseAsJSON[model.TokenResponse](w, r)
+}
@@ -0,0 +1,54 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type RefreshTokenExchangeTestSuite struct {
+	suite.Suite
+	refreshTokens *MockCollectionHandle
+	provider      *TournabyteIdentityProviderService
+}
+
+func TestRefreshTokenExchange(t *testing.T) {
+	suite.Run(t, new(RefreshTokenExchangeTestSuite))
+}
+
+func (s *RefreshTokenExchangeTestSuite) SetupTest() {
+	s.refreshTokens = new(MockCollectionHandle)
+	s.provider = &TournabyteIdentityProviderService{
+		collections: mockCollections(map[string]*MockCollectionHandle{"refresh_tokens": s.refreshTokens}),
+	}
+}
+
+func (s *RefreshTokenExchangeTestSuite) TestOtherClientsTokenIsInvalidGrant() {
+	issued := model.RefreshToken{TokenHash: hashOpaqueToken("token"), FamilyId: "family", AccountId: bson.NewObjectID(), ClientId: "owner"}
+	s.refreshTokens.On("FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&model.RefreshToken{}, mongo.ErrNoDocuments, nil),
+	)
+	s.refreshTokens.On("FindOne", mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&issued, nil, nil))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+	s.provider.exchangeRefreshToken(w, r, &model.Client{Id: "intruder"}, url.Values{"refresh_token": {"token"}})
+
+	var body model.OAuthErrorResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(s.T(), http.StatusBadRequest, w.Code)
+	assert.Equal(s.T(), "invalid_grant", body.Error)
+	s.refreshTokens.AssertNotCalled(s.T(), "UpdateMany", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,133 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/tournabyte/idp/model"
+)
+
+func (provider *TournabyteIdentityProviderService) revokeToken(w http.ResponseWriter, r *http.Request) {
+	form, ok := r.Context().Value(DECODED_FORM_BODY).(url.Values)
+	if !ok || form.Get("token") == "" {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_request", Description: "Required token parameter is not present"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid revocation request")
+	}
+
+	var client *model.Client
+	var clientErr error
+	_, _, basicAuth := r.BasicAuth()
+	if basicAuth || form.Get("client_id") != "" {
+		client, clientErr = provider.authenticateClient(r.Context(), r, form)
+	}
+	if clientErr != nil {
+		if basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "invalid_client", Description: "Client authentication failed"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid revocation request")
+	}
+
+	var clientId string
+	if client != nil {
+		clientId = client.Id
+	}
+
+	var revokeErr error
+	if form.Get("token_type_hint") == "refresh_token" {
+		if revoked, err := provider.revokeRefreshToken(r.Context(), form.Get("token"), clientId); revoked || err != nil {
+			revokeErr = err
+		} else {
+			_, revokeErr = provider.revokeAccessToken(r.Context(), form.Get("token"), clientId)
+		}
+	} else {
+		if revoked, err := provider.revokeAccessToken(r.Context(), form.Get("token"), clientId); revoked || err != nil {
+			revokeErr = err
+		} else {
+			_, revokeErr = provider.revokeRefreshToken(r.Context(), form.Get("token"), clientId)
+		}
+	}
+
+	if revokeErr != nil {
+		log.Printf("Failed to revoke token: %v", revokeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusServiceUnavailable),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.OAuthErrorResponse{Error: "temporarily_unavailable", Description: "Token could not be revoked"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Token revocation failed")
+	}
+
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			struct{}{},
+		))
+	EmitResponseAsJSON[struct{}](w, r)
+}
+
+// revokeAccessToken reports false without error for anything that is not a live access token issued to the
+// client, since RFC 7009 treats revoking an invalid token as a success.
+func (provider *TournabyteIdentityProviderService) revokeAccessToken(ctx context.Context, raw string, clientId string) (bool, error) {
+	claims, parseErr := provider.parseAccessToken(raw)
+	if parseErr != nil || claims.Expiry == nil || claims.ClientId != clientId {
+		return false, nil
+	}
+
+	revokedTokensCollectionHandle := model.NewTournabyteRevokedTokenRepository(
+		provider.collection("revoked_tokens"),
+	)
+	if err := revokedTokensCollectionHandle.Revoke(ctx, claims.ID, claims.Expiry.Time()); err != nil {
+		return false, err
+	}
+	log.Printf("Revoked access token %s", claims.ID)
+	return true, nil
+}
+
+func (provider *TournabyteIdentityProviderService) revokeRefreshToken(ctx context.Context, raw string, clientId string) (bool, error) {
+	refreshTokensCollectionHandle := model.NewTournabyteRefreshTokenRepository(
+		provider.collection("refresh_tokens"),
+	)
+	token, findErr := refreshTokensCollectionHandle.FindByHash(ctx, hashOpaqueToken(raw))
+	if findErr != nil || token.ClientId != clientId {
+		return false, nil
+	}
+
+	if err := refreshTokensCollectionHandle.RevokeFamily(ctx, token.FamilyId); err != nil {
+		return false, err
+	}
+	log.Printf("Revoked refresh token family %s", token.FamilyId)
+	return true, nil
+}
@@ -5,6 +5,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -17,7 +18,10 @@ import (
 	"github.com/alexedwards/argon2id"
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/mailer"
 	"github.com/tournabyte/idp/model"
+	"github.com/tournabyte/idp/passwordpolicy"
+	"github.com/tournabyte/idp/ratelimit"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -29,6 +33,17 @@ type TournabyteIdentityProviderService struct {
 	mux                *http.ServeMux
 	env                *model.ApplicationOptions
 	sessionTokenSigner jose.Signer
+	signingKeys        *signingKeyRing
+	tokens             *tokenSettings
+	claimsEnrichers    []ClaimsEnricher
+	mailer             mailer.Mailer
+	mailTemplates      *mailer.Templates
+	passwordPolicy     *passwordpolicy.Policy
+	rateLimitStore     ratelimit.Store
+	clientIPs          *ratelimit.ClientIPResolver
+	perIPLimit         ratelimit.Limit
+	perEmailLimit      ratelimit.Limit
+	collections        func(name string) model.DocumentCollection
 }
 
 func NewIdentityProviderServer(opts *model.ApplicationOptions) (*TournabyteIdentityProviderService, error) {
@@ -39,6 +54,21 @@ func NewIdentityProviderServer(opts *model.ApplicationOptions) (*TournabyteIdent
 	tbyteService.env = opts
 	tbyteService.configureHandlers()
 
+	tokens, settingsErr := newTokenSettings(opts)
+	if settingsErr != nil {
+		return nil, fmt.Errorf("Invalid token configuration: %w", settingsErr)
+	}
+	tbyteService.tokens = tokens
+	tbyteService.AddClaimsEnricher(ClaimsEnricherFunc(accountClaims))
+
+	if mailErr := tbyteService.initializeMailer(); mailErr != nil {
+		return nil, fmt.Errorf("Invalid mail configuration: %w", mailErr)
+	}
+
+	if policyErr := tbyteService.initializePasswordPolicy(); policyErr != nil {
+		return nil, fmt.Errorf("Invalid password policy: %w", policyErr)
+	}
+
 	if connErr := tbyteService.connectDatabase(); connErr != nil {
 		return nil, fmt.Errorf("Could not connect to database: %w", connErr)
 	}
@@ -47,26 +77,71 @@ func NewIdentityProviderServer(opts *model.ApplicationOptions) (*TournabyteIdent
 		return nil, fmt.Errorf("Database unreachable: %w", pingErr)
 	}
 
-	if signErr := tbyteService.initializeTokenSigner(); signErr != nil {
+	if indexErr := tbyteService.ensureIndexes(ctx); indexErr != nil {
+		return nil, fmt.Errorf("Failed to create collection indexes: %w", indexErr)
+	}
+
+	if limitErr := tbyteService.initializeRateLimits(); limitErr != nil {
+		return nil, fmt.Errorf("Invalid rate limit configuration: %w", limitErr)
+	}
+
+	if signErr := tbyteService.initializeTokenSigner(ctx); signErr != nil {
 		return nil, fmt.Errorf("Failed to create token signer: %w", signErr)
 	}
 
 	return &tbyteService, nil
 }
 
-func (provider *TournabyteIdentityProviderService) initializeTokenSigner() error {
-	signer, err := jose.NewSigner(
-		jose.SigningKey{Algorithm: jose.HS256, Key: []byte(provider.env.Serve.WebToken.Key)},
-		nil,
+func (provider *TournabyteIdentityProviderService) initializeTokenSigner(ctx context.Context) error {
+	alg, err := parseSigningAlgorithm(provider.env.Serve.WebToken.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	var kek []byte
+	if provider.env.Serve.WebToken.KeyEncryptionKey != "" {
+		if kek, err = parseKeyEncryptionKey(provider.env.Serve.WebToken.KeyEncryptionKey); err != nil {
+			return err
+		}
+	} else if isAsymmetricAlgorithm(alg) && !provider.env.Serve.WebToken.PlaintextKeys {
+		return errors.New("serve.jwt.key_encryption_key is required to store generated signing keys; set serve.jwt.plaintext_keys only if the signing_keys collection is otherwise protected")
+	}
+	if delay := provider.env.Serve.WebToken.PublishDelay; delay > 0 && delay < KEY_SET_MAX_AGE {
+		return fmt.Errorf("serve.jwt.publish_delay (%s) must not be shorter than the key set cache time (%s)", delay, KEY_SET_MAX_AGE)
+	}
+
+	ring, err := newSigningKeyRing(
+		ctx,
+		model.NewTournabyteSigningKeyRepository(provider.collection("signing_keys")),
+		alg,
+		provider.env.Serve.WebToken.KeyFile,
+		provider.env.Serve.WebToken.Key,
+		provider.env.Serve.WebToken.RotationInterval,
+		provider.env.Serve.WebToken.KeyRetention,
+		provider.env.Serve.WebToken.PublishDelay,
+		kek,
 	)
 	if err != nil {
 		return err
 	}
 
-	provider.sessionTokenSigner = signer
+	provider.signingKeys = ring
+	provider.sessionTokenSigner = ring
+	log.Printf("Signing tokens with %s key %s", alg, ring.ActiveKeyId())
 	return nil
 }
 
+// RotateSigningKey publishes a new signing key and returns its key id along with the time it starts signing.
+func (provider *TournabyteIdentityProviderService) RotateSigningKey(ctx context.Context) (string, time.Time, error) {
+	if err := provider.signingKeys.Rotate(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+	if kid, activatesAt, ok := provider.signingKeys.PendingKey(); ok {
+		return kid, activatesAt, nil
+	}
+	return provider.signingKeys.ActiveKeyId(), time.Now(), nil
+}
+
 func (provider *TournabyteIdentityProviderService) connectDatabase() error {
 	var connectOptions options.ClientOptions
 
@@ -86,6 +161,14 @@ func (provider *TournabyteIdentityProviderService) connectDatabase() error {
 	return nil
 }
 
+// collection returns a collection of the idp database, or the stand-in tests installed through collections.
+func (provider *TournabyteIdentityProviderService) collection(name string) model.DocumentCollection {
+	if provider.collections != nil {
+		return provider.collections(name)
+	}
+	return provider.db.Database("idp").Collection(name)
+}
+
 func (provider *TournabyteIdentityProviderService) pingDatabase(ctx context.Context) error {
 	if provider.db == nil {
 		return fmt.Errorf("cannot ping a null deployment")
@@ -94,21 +177,271 @@ func (provider *TournabyteIdentityProviderService) pingDatabase(ctx context.Cont
 	return provider.db.Ping(ctx, readpref.Primary())
 }
 
+func (provider *TournabyteIdentityProviderService) ensureIndexes(ctx context.Context) error {
+	database := provider.db.Database("idp")
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(database.Collection("accounts"))
+	backfilled, skipped, backfillErr := accountsCollectionHandle.BackfillNormalizedEmails(ctx)
+	if backfillErr != nil {
+		return fmt.Errorf("account email normalization backfill: %w", backfillErr)
+	}
+	if backfilled > 0 {
+		log.Printf("Normalized the email of %d accounts created before normalization", backfilled)
+	}
+	for _, id := range skipped {
+		log.Printf("Email of account %s is invalid or collides with another account and was left unnormalized", id.Hex())
+	}
+
+	// Partial so that accounts the backfill had to skip do not collide on a missing field.
+	if _, err := database.Collection("accounts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email_normalized", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+			bson.D{{Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: true}}}},
+		),
+	}); err != nil {
+		return fmt.Errorf("account email uniqueness index: %w", err)
+	}
+
+	if _, err := database.Collection("authorization_codes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return fmt.Errorf("authorization code expiry index: %w", err)
+	}
+
+	if _, err := database.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "account_id", Value: 1}}},
+	}); err != nil {
+		return fmt.Errorf("refresh token indexes: %w", err)
+	}
+
+	if _, err := database.Collection("revoked_tokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return fmt.Errorf("revoked token expiry index: %w", err)
+	}
+
+	if _, err := database.Collection("password_resets").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return fmt.Errorf("password reset expiry index: %w", err)
+	}
+
+	if _, err := database.Collection("rate_limits").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return fmt.Errorf("rate limit expiry index: %w", err)
+	}
+
+	return nil
+}
+
 func (provider *TournabyteIdentityProviderService) configureHandlers() {
 	provider.mux = http.NewServeMux()
 	provider.mux.HandleFunc(
 		CREATE_ACCOUNT_ENDPOINT,
-		SetRequestTimeout(ReadRequestBodyAsJSON[model.CreateAccountRequest](provider.createAccount), 30),
+		SetRequestTimeout(
+			provider.LimitPerClientIP("signup")(
+				ReadRequestBodyAsJSON[model.CreateAccountRequest](provider.LimitPerEmail("signup", newAccountEmail)(provider.createAccount)),
+			),
+			30,
+		),
 	)
 
 	provider.mux.HandleFunc(
 		LOOKUP_ACCOUNT_ENDPOINT,
-		SetRequestTimeout(ExtractPathParameters(provider.findAccountById, "id"), 30),
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_READ)(ExtractPathParameters(provider.findAccountById, "id")), 30),
+	)
+
+	provider.mux.HandleFunc(
+		LIST_ACCOUNTS_ENDPOINT,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_READ)(ExtractQueryParameters(provider.listAccounts)), 30),
+	)
+
+	provider.mux.HandleFunc(
+		UPDATE_ACCOUNT_ENDPOINT,
+		SetRequestTimeout(
+			provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(
+				ExtractPathParameters(
+					RequireContentType(ReadRequestBodyAsJSON[map[string]json.RawMessage](provider.updateAccount), MERGE_PATCH_MEDIA_TYPE),
+					"id",
+				),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		DEACTIVATE_ACCOUNT_ENDPOINT,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(ExtractPathParameters(provider.deactivateAccount, "id")), 30),
+	)
+
+	provider.mux.HandleFunc(
+		CHANGE_PASSWORD_ENDPOINT,
+		SetRequestTimeout(
+			provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(
+				ExtractPathParameters(ReadRequestBodyAsJSON[model.PasswordChangeRequest](provider.changePassword), "id"),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		UNLOCK_ACCOUNT_ENDPOINT,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(ExtractPathParameters(provider.unlockAccount, "id")), 30),
+	)
+
+	provider.mux.HandleFunc(
+		ENROLL_TOTP_ENDPOINT,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(ExtractPathParameters(provider.enrollTOTP, "id")), 30),
+	)
+
+	provider.mux.HandleFunc(
+		CONFIRM_TOTP_ENDPOINT,
+		SetRequestTimeout(
+			provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(
+				ExtractPathParameters(ReadRequestBodyAsJSON[model.TOTPConfirmationRequest](provider.confirmTOTP), "id"),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		RECOVERY_CODES_ENDPOINT,
+		SetRequestTimeout(
+			provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(
+				ExtractPathParameters(ReadRequestBodyAsJSON[model.RecoveryCodesRequest](provider.regenerateRecoveryCodes), "id"),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		DISABLE_MFA_ENDPOINT,
+		SetRequestTimeout(
+			provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(
+				ExtractPathParameters(ReadRequestBodyAsJSON[model.MFADisableRequest](provider.disableMFA), "id"),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		PURGE_ACCOUNT_ENDPOINT,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_ACCOUNTS_WRITE)(ExtractPathParameters(provider.purgeAccount, "id")), 30),
+	)
+
+	provider.mux.HandleFunc(
+		REACTIVATE_ACCOUNT_ENDPOINT,
+		SetRequestTimeout(
+			provider.LimitPerClientIP("login")(
+				ReadRequestBodyAsJSON[model.LoginAttempt](provider.LimitPerEmail("login", loginAttemptEmail)(provider.reactivateAccount)),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		VERIFY_EMAIL_ENDPOINT,
+		SetRequestTimeout(ExtractPathParameters(ReadRequestBodyAsJSON[model.EmailVerificationRequest](provider.verifyEmail), "id"), 30),
+	)
+
+	provider.mux.HandleFunc(
+		RESEND_EMAIL_VERIFICATION_ENDPOINT,
+		SetRequestTimeout(
+			provider.LimitPerClientIP("verify-email")(
+				ExtractPathParameters(provider.LimitPerAccount("verify-email", pathAccountId)(provider.resendEmailVerification), "id"),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		PASSWORD_RESET_ENDPOINT,
+		SetRequestTimeout(
+			provider.LimitPerClientIP("password-reset")(
+				ReadRequestBodyAsJSON[model.PasswordResetRequest](provider.LimitPerEmail("password-reset", passwordResetEmail)(provider.requestPasswordReset)),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		PASSWORD_RESET_CONFIRM_ENDPOINT,
+		SetRequestTimeout(ReadRequestBodyAsJSON[model.PasswordResetConfirmation](provider.confirmPasswordReset), 30),
 	)
 
 	provider.mux.HandleFunc(
 		AUTHORIZE_LOGIN,
-		SetRequestTimeout(ReadRequestBodyAsJSON[model.LoginAttempt](provider.authorizeAccount), 30),
+		SetRequestTimeout(
+			provider.LimitPerClientIP("login")(
+				ReadRequestBodyAsJSON[model.LoginAttempt](provider.LimitPerEmail("login", loginAttemptEmail)(provider.authorizeAccount)),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		MFA_CHALLENGE_ENDPOINT,
+		SetRequestTimeout(
+			provider.LimitPerClientIP("login")(
+				ReadRequestBodyAsJSON[model.MFAChallengeRequest](provider.LimitPerAccount("login", provider.mfaChallengeAccount)(provider.completeMFAChallenge)),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		OAUTH_AUTHORIZE,
+		SetRequestTimeout(
+			provider.LimitPerClientIP("login")(
+				ExtractQueryParameters(
+					ReadRequestBodyAsJSON[model.LoginAttempt](provider.LimitPerEmail("login", loginAttemptEmail)(provider.issueAuthorizationCode)),
+					"response_type", "client_id", "redirect_uri",
+				),
+			),
+			30,
+		),
+	)
+
+	provider.mux.HandleFunc(
+		OAUTH_TOKEN,
+		SetRequestTimeout(ReadRequestBodyAsForm(provider.exchangeToken), 30),
+	)
+
+	provider.mux.HandleFunc(
+		OAUTH_REVOKE,
+		SetRequestTimeout(ReadRequestBodyAsForm(provider.revokeToken), 30),
+	)
+
+	provider.mux.HandleFunc(
+		OAUTH_INTROSPECT,
+		SetRequestTimeout(ReadRequestBodyAsForm(provider.introspectToken), 30),
+	)
+
+	provider.mux.HandleFunc(
+		USERINFO,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_OPENID)(provider.userInfo), 30),
+	)
+
+	provider.mux.HandleFunc(
+		USERINFO_POST,
+		SetRequestTimeout(provider.RequireBearerToken(SCOPE_OPENID)(provider.userInfo), 30),
+	)
+
+	provider.mux.HandleFunc(
+		OPENID_CONFIGURATION,
+		SetRequestTimeout(provider.describeProvider, 30),
+	)
+
+	provider.mux.HandleFunc(
+		JSON_WEB_KEY_SET,
+		SetRequestTimeout(provider.publishSigningKeys, 30),
 	)
 
 }
@@ -119,6 +452,10 @@ func (provider *TournabyteIdentityProviderService) Run() {
 		Handler: provider.mux,
 	}
 
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go provider.signingKeys.Watch(watchCtx)
+
 	go func() {
 		log.Printf("Starting server on port %d", provider.env.Serve.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -143,8 +480,23 @@ func (provider *TournabyteIdentityProviderService) Run() {
 
 func (provider *TournabyteIdentityProviderService) findAccountById(w http.ResponseWriter, r *http.Request) {
 	if idHex, ok := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]; ok {
+		if principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal); principal == nil || !principal.CanManage(idHex) {
+			log.Printf("Principal may not look up account %s", idHex)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "FORBIDDEN", Message: "Not permitted to access the requested resource"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Principal not permitted")
+		}
+
 		accountsCollectionHandle := model.NewTournabyteAccountRepository(
-			provider.db.Database("idp").Collection("accounts"),
+			provider.collection("accounts"),
 		)
 		account, findErr := accountsCollectionHandle.FindById(r.Context(), idHex)
 
@@ -175,6 +527,20 @@ func (provider *TournabyteIdentityProviderService) findAccountById(w http.Respon
 			defer RecoverResponse(w, r)
 			panic("ID parameter invalid")
 
+		case findErr != nil:
+			log.Printf("Failed to look up account %s: %v", idHex, findErr)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_NOT_READ", Message: "Account could not be read"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Account lookup failed")
+
 		default:
 			r = r.WithContext(
 				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
@@ -206,14 +572,56 @@ func (provider *TournabyteIdentityProviderService) findAccountById(w http.Respon
 
 func (provider *TournabyteIdentityProviderService) createAccount(w http.ResponseWriter, r *http.Request) {
 	if newAccountDetails, ok := r.Context().Value(DECODED_JSON_BODY).(model.CreateAccountRequest); ok {
+		if rejection := provider.rejectPassword(r.Context(), newAccountDetails.NewAccountPassword, newAccountDetails.NewAccountEmail); rejection != nil {
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, rejection.status),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					rejection.response,
+				))
+			defer RecoverResponse(w, r)
+			panic("Password rejected")
+		}
+
 		accountsCollectionHandle := model.NewTournabyteAccountRepository(
-			provider.db.Database("idp").Collection("accounts"),
+			provider.collection("accounts"),
 		)
 		newAccountRecord := model.Account{
 			Email:    newAccountDetails.NewAccountEmail,
 			LoginKey: provider.mustHashPassword(newAccountDetails.NewAccountPassword),
 		}
-		if createErr := accountsCollectionHandle.Create(r.Context(), &newAccountRecord); createErr != nil {
+		createErr := accountsCollectionHandle.Create(r.Context(), &newAccountRecord)
+		switch {
+		case errors.Is(createErr, model.ErrInvalidEmail):
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "EMAIL_INVALID", Message: "Email must be a bare address"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Invalid email address")
+
+		case errors.Is(createErr, model.ErrAccountExists):
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusConflict),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_EXISTS", Message: "An account with this email already exists"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Duplicate account")
+
+		case createErr != nil:
 			log.Printf("Did not create the account: %v", createErr)
 			r = r.WithContext(
 				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
@@ -226,9 +634,10 @@ func (provider *TournabyteIdentityProviderService) createAccount(w http.Response
 				))
 			defer RecoverResponse(w, r)
 			panic("Account creation failed")
-
 		}
+
 		log.Printf("Created the account: %v", newAccountRecord)
+		provider.sendEmailVerification(r.Context(), &newAccountRecord)
 		r = r.WithContext(
 			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusCreated),
 		)
@@ -256,16 +665,121 @@ func (provider *TournabyteIdentityProviderService) createAccount(w http.Response
 	}
 }
 
+const SESSION_TOKEN_SCOPE = "openid email profile accounts:read accounts:write"
+
+var (
+	errInvalidCredentials = errors.New("invalid email or password")
+	errAccountLocked      = errors.New("account locked")
+	errAccountDeactivated = errors.New("account deactivated")
+	errEmailNotVerified   = errors.New("email not verified")
+)
+
+func (provider *TournabyteIdentityProviderService) lockoutPolicy() model.LockoutPolicy {
+	opts := provider.env.Accounts.Lockout
+	return model.LockoutPolicy{
+		Threshold:  opts.Threshold,
+		BaseDelay:  opts.BaseDelay,
+		MaxDelay:   opts.MaxDelay,
+		ResetAfter: opts.ResetAfter,
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) recordFailedLogin(ctx context.Context, accounts *model.TournabyteAccountRepository, acc *model.Account) {
+	lockedUntil, err := accounts.RecordFailedLogin(ctx, acc.Id, provider.lockoutPolicy())
+	if err != nil {
+		log.Printf("Could not record the failed login of account %s: %v", acc.Id.Hex(), err)
+	} else if !lockedUntil.IsZero() {
+		log.Printf("Account %s locked until %s", acc.Id.Hex(), lockedUntil.Format(time.RFC3339))
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) authenticateLoginAttempt(ctx context.Context, accounts *model.TournabyteAccountRepository, loginAttempt model.LoginAttempt) (*model.Account, error) {
+	acc, err := accounts.FindByEmail(ctx, loginAttempt.LoginId)
+	if err != nil {
+		log.Printf("No account found with email: %s", loginAttempt.LoginId)
+		return nil, errInvalidCredentials
+	}
+
+	if acc.IsLocked() {
+		log.Printf("Account %s is locked until %s", acc.Id.Hex(), acc.LockedUntil.Format(time.RFC3339))
+		return acc, errAccountLocked
+	}
+
+	if match, err := argon2id.ComparePasswordAndHash(loginAttempt.LoginSecret, acc.LoginKey); err != nil {
+		log.Printf("Error during password comparison: %v", err)
+		return nil, errInvalidCredentials
+	} else if !match {
+		log.Printf("Comparison succeeded but no match found")
+		provider.recordFailedLogin(ctx, accounts, acc)
+		return nil, errInvalidCredentials
+	}
+
+	log.Printf("Comparison succeeded and match detected")
+	// With MFA the failures are only forgotten once the second factor passes, or guessing codes would be unbounded.
+	if !acc.MFAEnabled {
+		accounts.ResetLoginAttempts(ctx, acc.Id)
+	}
+	if !acc.Active {
+		return acc, errAccountDeactivated
+	}
+	if provider.env.Accounts.RequireVerifiedEmail && !acc.EmailVerified {
+		return acc, errEmailNotVerified
+	}
+	return acc, nil
+}
+
 func (provider *TournabyteIdentityProviderService) authorizeAccount(w http.ResponseWriter, r *http.Request) {
 	if loginAttempt, ok := r.Context().Value(DECODED_JSON_BODY).(model.LoginAttempt); ok {
 		accountsCollectionHandle := model.NewTournabyteAccountRepository(
-			provider.db.Database("idp").Collection("accounts"),
+			provider.collection("accounts"),
 		)
-		if acc, err := accountsCollectionHandle.FindByEmail(r.Context(), loginAttempt.LoginId); err != nil {
-			log.Printf("No account found with email: %s", loginAttempt.LoginId)
+		acc, authErr := provider.authenticateLoginAttempt(r.Context(), accountsCollectionHandle, loginAttempt)
+
+		switch {
+		case errors.Is(authErr, errAccountLocked):
+			setRetryAfter(w, acc.LockedUntil)
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusTooManyRequests),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_LOCKED", Message: "Account is temporarily locked after repeated failed logins"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Log in attempt for a locked account")
+
+		case errors.Is(authErr, errAccountDeactivated):
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "ACCOUNT_DEACTIVATED", Message: "Account is deactivated"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Log in attempt for a deactivated account")
+
+		case errors.Is(authErr, errEmailNotVerified):
 			r = r.WithContext(
 				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusForbidden),
 			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "EMAIL_NOT_VERIFIED", Message: "Email address must be verified before logging in"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Log in attempt before email verification")
+
+		case authErr != nil:
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
+			)
 			r = r.WithContext(
 				context.WithValue(
 					r.Context(),
@@ -275,63 +789,11 @@ func (provider *TournabyteIdentityProviderService) authorizeAccount(w http.Respo
 			defer RecoverResponse(w, r)
 			panic("Invalid log in attempt")
 
-		} else {
-			if acc.LoginAttemptsSinceLastSuccess > 5 {
-				log.Printf("Too many attempts at logging in")
-				r = r.WithContext(
-					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
-				)
-				r = r.WithContext(
-					context.WithValue(
-						r.Context(),
-						HANDLER_RESPONSE_BODY,
-						model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "Account locked"},
-					))
-				defer RecoverResponse(w, r)
-				panic("Invalid log in attempt")
-			}
-			if match, err := argon2id.ComparePasswordAndHash(loginAttempt.LoginSecret, acc.LoginKey); err != nil {
-				log.Printf("Error during password comparison: %v", err)
-				r = r.WithContext(
-					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
-				)
-				r = r.WithContext(
-					context.WithValue(
-						r.Context(),
-						HANDLER_RESPONSE_BODY,
-						model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "Invalid email or password"},
-					))
-				defer RecoverResponse(w, r)
-				panic("Invalid log in attempt")
-			} else if !match {
-				log.Printf("Comparison succeeded but no match found")
-				r = r.WithContext(
-					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnauthorized),
-				)
-				r = r.WithContext(
-					context.WithValue(
-						r.Context(),
-						HANDLER_RESPONSE_BODY,
-						model.ErrorResponse{Reason: "NO_MATCHING_RESOURCE", Message: "Invalid email or password"},
-					))
-				accountsCollectionHandle.IncrementLoginAttempts(r.Context(), acc.Id)
-				defer RecoverResponse(w, r)
-				panic("Invalid log in attempt")
-			} else {
-				log.Printf("Comparison succeeded and match detected")
-				accountsCollectionHandle.ResetLoginAttempts(r.Context(), acc.Id)
-				r = r.WithContext(
-					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusCreated),
-				)
-				r = r.WithContext(
-					context.WithValue(
-						r.Context(),
-						HANDLER_RESPONSE_BODY,
-						model.SuccessfulAuthenticationResponse{Token: provider.makeSessionToken(acc.Id.String())},
-					))
-				EmitResponseAsJSON[model.SuccessfulAuthenticationResponse](w, r)
-
-			}
+		case acc.MFAEnabled:
+			provider.challengeSecondFactor(w, r, acc)
+
+		default:
+			provider.establishSession(w, r, acc)
 		}
 	} else {
 		r = r.WithContext(
@@ -349,6 +811,43 @@ func (provider *TournabyteIdentityProviderService) authorizeAccount(w http.Respo
 	}
 }
 
+// establishSession answers a completed login with a session token and a refresh token.
+func (provider *TournabyteIdentityProviderService) establishSession(w http.ResponseWriter, r *http.Request, acc *model.Account) {
+	var refreshToken string
+	sessionToken, issueErr := provider.makeSessionToken(r.Context(), acc)
+	if issueErr == nil {
+		refreshToken, issueErr = provider.issueRefreshToken(r.Context(), acc.Id, "", SESSION_TOKEN_SCOPE, time.Now(), "")
+	}
+	if issueErr != nil {
+		log.Printf("Could not establish a session: %v", issueErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "SESSION_NOT_CREATED", Message: "Could not establish a session"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Refresh token creation failed")
+	}
+
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusCreated),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			model.SuccessfulAuthenticationResponse{
+				Token:        sessionToken,
+				RefreshToken: refreshToken,
+			},
+		))
+	EmitResponseAsJSON[model.SuccessfulAuthenticationResponse](w, r)
+}
+
 func (provider *TournabyteIdentityProviderService) mustHashPassword(passwd string) string {
 	hash, err := argon2id.CreateHash(passwd, argon2id.DefaultParams)
 	if err != nil {
@@ -357,18 +856,27 @@ func (provider *TournabyteIdentityProviderService) mustHashPassword(passwd strin
 	return hash
 }
 
-func (provider *TournabyteIdentityProviderService) makeSessionToken(userId string) string {
-	cl := jwt.Claims{
-		Issuer:   "example.com",
-		Audience: jwt.Audience{"example-audience"},
-		Expiry:   jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-		ID:       userId,
+func (provider *TournabyteIdentityProviderService) makeSessionToken(ctx context.Context, acc *model.Account) (string, error) {
+	return provider.makeAccessToken(ctx, acc, "", SESSION_TOKEN_SCOPE)
+}
+
+func (provider *TournabyteIdentityProviderService) makeAccessToken(ctx context.Context, acc *model.Account, clientId string, scope string) (string, error) {
+	cl := accessTokenClaims{
+		Claims: jwt.Claims{
+			Issuer:   provider.tokenIssuer(),
+			Subject:  acc.Id.Hex(),
+			Audience: provider.tokens.audienceFor(clientId),
+			Expiry:   jwt.NewNumericDate(time.Now().Add(provider.tokens.accessTokenLifetime)),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ID:       generateOpaqueToken(TOKEN_ID_BYTES),
+		},
+		Scope:    scope,
+		ClientId: clientId,
 	}
 
-	raw, err := jwt.Signed(provider.sessionTokenSigner).Claims(cl).Serialize()
-	if err != nil {
-		panic(fmt.Sprintf("JWT creation failed: %v", err))
+	extra, enrichErr := provider.enrichClaims(ctx, acc, TokenIssuance{TokenType: ACCESS_TOKEN_TYPE, ClientId: clientId, Scopes: splitScope(scope)})
+	if enrichErr != nil {
+		return "", enrichErr
 	}
-	return raw
+	return jwt.Signed(provider.sessionTokenSigner).Claims(extra).Claims(cl).Serialize()
 }
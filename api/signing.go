@@ -0,0 +1,191 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	RSA_KEY_BITS            = 3072
+	HMAC_MIN_SECRET_SIZE    = 32
+	KEY_ENCRYPTION_KEY_SIZE = 32
+)
+
+var SUPPORTED_SIGNING_ALGORITHMS = []jose.SignatureAlgorithm{jose.HS256, jose.RS256, jose.ES256, jose.EdDSA}
+
+var errUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+func parseSigningAlgorithm(name string) (jose.SignatureAlgorithm, error) {
+	if name == "" {
+		return jose.HS256, nil
+	}
+	for _, alg := range SUPPORTED_SIGNING_ALGORITHMS {
+		if string(alg) == name {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errUnsupportedAlgorithm, name)
+}
+
+func isAsymmetricAlgorithm(alg jose.SignatureAlgorithm) bool {
+	return alg != jose.HS256
+}
+
+func generatePrivateKey(alg jose.SignatureAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case jose.RS256:
+		return rsa.GenerateKey(rand.Reader, RSA_KEY_BITS)
+	case jose.ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jose.EdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	default:
+		return nil, fmt.Errorf("%w: cannot generate a key for %s", errUnsupportedAlgorithm, alg)
+	}
+}
+
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("PKCS#8 key of type %T cannot sign", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+	}
+}
+
+func keyMatchesAlgorithm(key crypto.Signer, alg jose.SignatureAlgorithm) bool {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return alg == jose.RS256
+	case *ecdsa.PrivateKey:
+		return alg == jose.ES256 && k.Curve == elliptic.P256()
+	case ed25519.PrivateKey:
+		return alg == jose.EdDSA
+	default:
+		return false
+	}
+}
+
+func newSigningKey(key any, alg jose.SignatureAlgorithm) (jose.JSONWebKey, error) {
+	jwk := jose.JSONWebKey{Key: key, Algorithm: string(alg), Use: "sig"}
+
+	if secret, ok := key.([]byte); ok {
+		// go-jose cannot thumbprint symmetric keys, so apply RFC 7638 to the oct members directly
+		members := fmt.Sprintf(`{"k":"%s","kty":"oct"}`, base64.RawURLEncoding.EncodeToString(secret))
+		thumbprint := sha256.Sum256([]byte(members))
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+		return jwk, nil
+	}
+
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("could not compute key id: %w", err)
+	}
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	return jwk, nil
+}
+
+// newSymmetricSigningKey refuses secrets shorter than the HS256 output, which go-jose would only reject when signing.
+func newSymmetricSigningKey(secret string) (jose.JSONWebKey, error) {
+	if len(secret) < HMAC_MIN_SECRET_SIZE {
+		return jose.JSONWebKey{}, fmt.Errorf("serve.jwt.key must hold at least %d bytes for HS256", HMAC_MIN_SECRET_SIZE)
+	}
+	return newSigningKey([]byte(secret), jose.HS256)
+}
+
+func readPrivateKeyFile(keyFile string) (crypto.Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing key file: %w", err)
+	}
+	key, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse signing key file: %w", err)
+	}
+	return key, nil
+}
+
+func newTokenSigner(key jose.JSONWebKey) (jose.Signer, error) {
+	return jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+}
+
+// parseKeyEncryptionKey decodes the base64 AES-256 key that seals the signing keys stored in the database.
+func parseKeyEncryptionKey(encoded string) ([]byte, error) {
+	kek, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(kek) != KEY_ENCRYPTION_KEY_SIZE {
+		return nil, fmt.Errorf("serve.jwt.key_encryption_key must be %d base64 encoded bytes", KEY_ENCRYPTION_KEY_SIZE)
+	}
+	return kek, nil
+}
+
+// sealPrivateKey encrypts a PEM encoded private key with AES-GCM. The key id is authenticated along with it so that
+// sealed keys cannot be swapped between records.
+func sealPrivateKey(kek []byte, kid string, privateKey []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, privateKey, []byte(kid)), nil
+}
+
+func openPrivateKey(kek []byte, kid string, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed private key is truncated")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(kid))
+}
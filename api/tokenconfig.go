@@ -0,0 +1,111 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	DEFAULT_ACCESS_TOKEN_LIFETIME  = 1 * time.Hour
+	DEFAULT_REFRESH_TOKEN_LIFETIME = 30 * 24 * time.Hour
+)
+
+// tokenSettings holds the validated serve.jwt options that shape every token the provider issues and accepts.
+type tokenSettings struct {
+	issuer               string
+	audience             string
+	clientAudiences      map[string][]string
+	accessTokenLifetime  time.Duration
+	refreshTokenLifetime time.Duration
+	leeway               time.Duration
+}
+
+func newTokenSettings(opts *model.ApplicationOptions) (*tokenSettings, error) {
+	cfg := opts.Serve.WebToken
+	settings := tokenSettings{
+		issuer:               strings.TrimSuffix(cfg.Issuer, "/"),
+		audience:             cfg.Audience,
+		clientAudiences:      make(map[string][]string, len(cfg.ClientAudiences)),
+		accessTokenLifetime:  cfg.AccessTokenTTL,
+		refreshTokenLifetime: cfg.RefreshTokenTTL,
+		leeway:               cfg.Leeway,
+	}
+
+	if settings.issuer == "" {
+		return nil, errors.New("serve.jwt.issuer must be set")
+	}
+	issuer, err := url.Parse(settings.issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return nil, fmt.Errorf("serve.jwt.issuer must be an absolute http(s) URL without query or fragment, got %q", cfg.Issuer)
+	}
+	if settings.audience == "" {
+		return nil, errors.New("serve.jwt.audience must be set")
+	}
+
+	for _, entry := range cfg.ClientAudiences {
+		if entry.ClientId == "" {
+			return nil, errors.New("serve.jwt.clients entries must name a client")
+		}
+		if _, duplicate := settings.clientAudiences[entry.ClientId]; duplicate {
+			return nil, fmt.Errorf("serve.jwt.clients lists client %s more than once", entry.ClientId)
+		}
+		if len(entry.Audiences) == 0 || slices.Contains(entry.Audiences, "") {
+			return nil, fmt.Errorf("serve.jwt.clients entry for client %s must list non-empty audiences", entry.ClientId)
+		}
+		settings.clientAudiences[entry.ClientId] = slices.Clone(entry.Audiences)
+	}
+
+	if settings.accessTokenLifetime < 0 || settings.refreshTokenLifetime < 0 || settings.leeway < 0 {
+		return nil, errors.New("serve.jwt.access_ttl, serve.jwt.refresh_ttl and serve.jwt.leeway cannot be negative")
+	}
+	if settings.accessTokenLifetime == 0 {
+		settings.accessTokenLifetime = DEFAULT_ACCESS_TOKEN_LIFETIME
+	}
+	if settings.refreshTokenLifetime == 0 {
+		settings.refreshTokenLifetime = DEFAULT_REFRESH_TOKEN_LIFETIME
+	}
+	if settings.refreshTokenLifetime < settings.accessTokenLifetime {
+		return nil, fmt.Errorf("serve.jwt.refresh_ttl (%s) must not be shorter than serve.jwt.access_ttl (%s)", settings.refreshTokenLifetime, settings.accessTokenLifetime)
+	}
+
+	retention := cfg.KeyRetention
+	if retention <= 0 {
+		retention = DEFAULT_KEY_RETENTION
+	}
+	if retention < settings.accessTokenLifetime {
+		return nil, fmt.Errorf("serve.jwt.retention (%s) must not be shorter than serve.jwt.access_ttl (%s)", retention, settings.accessTokenLifetime)
+	}
+
+	return &settings, nil
+}
+
+// audienceFor returns the audiences of access tokens issued to the given client; first-party tokens use an empty client id.
+func (settings *tokenSettings) audienceFor(clientId string) jwt.Audience {
+	if audiences, ok := settings.clientAudiences[clientId]; ok {
+		return jwt.Audience(slices.Clone(audiences))
+	}
+	return jwt.Audience{settings.audience}
+}
+
+// acceptedAudiences lists every audience the provider hands out, any of which its own protected routes accept.
+func (settings *tokenSettings) acceptedAudiences() jwt.Audience {
+	accepted := jwt.Audience{settings.audience}
+	for _, audiences := range settings.clientAudiences {
+		for _, aud := range audiences {
+			if !slices.Contains(accepted, aud) {
+				accepted = append(accepted, aud)
+			}
+		}
+	}
+	return accepted
+}
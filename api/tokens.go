@@ -0,0 +1,67 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	PKCE_METHOD_S256        = "S256"
+	PKCE_VERIFIER_MIN_CHARS = 43
+	PKCE_VERIFIER_MAX_CHARS = 128
+	TOKEN_ID_BYTES          = 16
+)
+
+type accessTokenClaims struct {
+	jwt.Claims
+	Scope    string `json:"scope,omitempty"`
+	ClientId string `json:"client_id,omitempty"`
+}
+
+func generateOpaqueToken(size int) string {
+	buf := make([]byte, size)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidCodeVerifier(verifier string) bool {
+	if len(verifier) < PKCE_VERIFIER_MIN_CHARS || len(verifier) > PKCE_VERIFIER_MAX_CHARS {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func verifyCodeChallenge(verifier string, challenge string, method string) bool {
+	if method != PKCE_METHOD_S256 || !isValidCodeVerifier(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func splitScope(scope string) []string {
+	return strings.Fields(scope)
+}
@@ -0,0 +1,80 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/model"
+)
+
+const (
+	SCOPE_OPENID  = "openid"
+	SCOPE_EMAIL   = "email"
+	SCOPE_PROFILE = "profile"
+)
+
+var SUPPORTED_CLAIMS = []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "name", "updated_at", "roles", "tenant"}
+
+type idTokenClaims struct {
+	jwt.Claims
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	Nonce    string           `json:"nonce,omitempty"`
+}
+
+func identityClaims(acc *model.Account, scopes []string) map[string]any {
+	claims := map[string]any{"sub": acc.Id.Hex()}
+
+	if slices.Contains(scopes, SCOPE_EMAIL) {
+		claims["email"] = acc.Email
+		claims["email_verified"] = acc.EmailVerified
+	}
+	if slices.Contains(scopes, SCOPE_PROFILE) {
+		claims["updated_at"] = acc.LastModified.Unix()
+		if acc.DisplayName != "" {
+			claims["name"] = acc.DisplayName
+		}
+	}
+	return claims
+}
+
+func (provider *TournabyteIdentityProviderService) makeIdToken(ctx context.Context, acc *model.Account, clientId string, scope string, nonce string, authTime time.Time) (string, error) {
+	cl := idTokenClaims{
+		Claims: jwt.Claims{
+			Issuer:   provider.tokenIssuer(),
+			Subject:  acc.Id.Hex(),
+			Audience: jwt.Audience{clientId},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(provider.tokens.accessTokenLifetime)),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+		AuthTime: jwt.NewNumericDate(authTime),
+		Nonce:    nonce,
+	}
+
+	extra, enrichErr := provider.enrichClaims(ctx, acc, TokenIssuance{TokenType: ID_TOKEN_TYPE, ClientId: clientId, Scopes: splitScope(scope)})
+	if enrichErr != nil {
+		return "", enrichErr
+	}
+	return jwt.Signed(provider.sessionTokenSigner).Claims(extra).Claims(identityClaims(acc, splitScope(scope))).Claims(cl).Serialize()
+}
+
+func (provider *TournabyteIdentityProviderService) userInfo(w http.ResponseWriter, r *http.Request) {
+	principal, _ := r.Context().Value(AUTHENTICATED_PRINCIPAL).(*AuthenticatedPrincipal)
+
+	w.Header().Set("Cache-Control", "no-store")
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			identityClaims(principal.Account, principal.Scopes),
+		))
+	EmitResponseAsJSON[map[string]any](w, r)
+}
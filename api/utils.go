@@ -8,26 +8,56 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/tournabyte/idp/model"
 )
 
 const (
-	CREATE_ACCOUNT_ENDPOINT = "POST /accounts"
-	LOOKUP_ACCOUNT_ENDPOINT = "GET /accounts/{id}"
-	AUTHORIZE_LOGIN         = "POST /accounts/authtoken"
+	CREATE_ACCOUNT_ENDPOINT            = "POST /accounts"
+	LOOKUP_ACCOUNT_ENDPOINT            = "GET /accounts/{id}"
+	AUTHORIZE_LOGIN                    = "POST /accounts/authtoken"
+	OAUTH_AUTHORIZE                    = "POST /oauth2/authorize"
+	OAUTH_TOKEN                        = "POST /oauth2/token"
+	OAUTH_REVOKE                       = "POST /oauth2/revoke"
+	OAUTH_INTROSPECT                   = "POST /oauth2/introspect"
+	OPENID_CONFIGURATION               = "GET /.well-known/openid-configuration"
+	JSON_WEB_KEY_SET                   = "GET /.well-known/jwks.json"
+	USERINFO                           = "GET /userinfo"
+	USERINFO_POST                      = "POST /userinfo"
+	UPDATE_ACCOUNT_ENDPOINT            = "PATCH /accounts/{id}"
+	DEACTIVATE_ACCOUNT_ENDPOINT        = "DELETE /accounts/{id}"
+	PURGE_ACCOUNT_ENDPOINT             = "DELETE /accounts/{id}/purge"
+	REACTIVATE_ACCOUNT_ENDPOINT        = "POST /accounts/reactivate"
+	LIST_ACCOUNTS_ENDPOINT             = "GET /accounts"
+	VERIFY_EMAIL_ENDPOINT              = "POST /accounts/{id}/verify-email"
+	RESEND_EMAIL_VERIFICATION_ENDPOINT = "POST /accounts/{id}/verify-email/resend"
+	PASSWORD_RESET_ENDPOINT            = "POST /accounts/password-reset"
+	PASSWORD_RESET_CONFIRM_ENDPOINT    = "POST /accounts/password-reset/confirm"
+	CHANGE_PASSWORD_ENDPOINT           = "PUT /accounts/{id}/password"
+	UNLOCK_ACCOUNT_ENDPOINT            = "POST /accounts/{id}/unlock"
+	ENROLL_TOTP_ENDPOINT               = "POST /accounts/{id}/mfa/totp"
+	CONFIRM_TOTP_ENDPOINT              = "POST /accounts/{id}/mfa/totp/confirm"
+	DISABLE_MFA_ENDPOINT               = "POST /accounts/{id}/mfa/disable"
+	RECOVERY_CODES_ENDPOINT            = "POST /accounts/{id}/mfa/recovery-codes"
+	MFA_CHALLENGE_ENDPOINT             = "POST /accounts/mfa/challenge"
 )
 
 type RequestContextKey string
 
 const (
-	DECODED_JSON_BODY     = "DECODED_BODY_VALUE"
-	PATH_VALUE_MAPPING    = "PATH_PARAMETERS"
-	QUERY_VALUE_MAPPING   = "QUERY_PARAMETERS"
-	HANDLER_RESPONSE_BODY = "RESPONSE_BODY"
-	HANDLER_STATUS_CODE   = "RESPONSE_STATUS"
+	DECODED_JSON_BODY       = "DECODED_BODY_VALUE"
+	DECODED_FORM_BODY       = "DECODED_FORM_VALUE"
+	PATH_VALUE_MAPPING      = "PATH_PARAMETERS"
+	QUERY_VALUE_MAPPING     = "QUERY_PARAMETERS"
+	HANDLER_RESPONSE_BODY   = "RESPONSE_BODY"
+	HANDLER_STATUS_CODE     = "RESPONSE_STATUS"
+	AUTHENTICATED_PRINCIPAL = "AUTHENTICATED_PRINCIPAL"
 )
 
 type HandlerFuncProcessingStep func(http.HandlerFunc) http.HandlerFunc
@@ -46,16 +76,15 @@ func RecoverResponse(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		errorResponse, okGotErrorResponse := ctx.Value(HANDLER_RESPONSE_BODY).(model.ErrorResponse)
-		if !okGotErrorResponse {
+		switch errorResponse := ctx.Value(HANDLER_RESPONSE_BODY).(type) {
+		case model.ErrorResponse, model.OAuthErrorResponse:
+			w.WriteHeader(errStatusCode)
+			emitter.Encode(errorResponse)
+		default:
 			log.Printf("Expected an error response struct to emit, got %v", errorResponse)
 			w.WriteHeader(http.StatusInternalServerError)
 			emitter.Encode(map[string]string{"Bad context": "Internal Server Error"})
-			return
 		}
-
-		w.WriteHeader(errStatusCode)
-		emitter.Encode(errorResponse)
 	}
 }
 
@@ -119,6 +148,110 @@ func ReadRequestBodyAsJSON[T any](next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func RequireContentType(next http.HandlerFunc, mediaType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if given, _, parseErr := mime.ParseMediaType(r.Header.Get("Content-Type")); parseErr != nil || given != mediaType {
+			log.Printf("Expected a request body of type %s but got %q", mediaType, r.Header.Get("Content-Type"))
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusUnsupportedMediaType),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.ErrorResponse{Reason: "UNSUPPORTED_MEDIA_TYPE", Message: "Request body must be of type " + mediaType},
+				))
+			defer RecoverResponse(w, r)
+			panic("Unsupported request media type")
+		}
+		next(w, r)
+	}
+}
+
+func ReadRequestBodyAsForm(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Looking to decode request body as form values")
+		body, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			log.Printf("Failed to read request body contents, updating request context with an error response")
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.OAuthErrorResponse{Error: "invalid_request", Description: "Request body could not be read"},
+				),
+			)
+			defer RecoverResponse(w, r)
+			panic("Failed to read request body contents")
+		}
+		defer r.Body.Close()
+
+		decodedForm, parseErr := url.ParseQuery(string(body))
+		if parseErr != nil {
+			log.Printf("Failed to parse the request body contents as form values, updating the request context with an error response")
+			r = r.WithContext(
+				context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+			)
+			r = r.WithContext(
+				context.WithValue(
+					r.Context(),
+					HANDLER_RESPONSE_BODY,
+					model.OAuthErrorResponse{Error: "invalid_request", Description: "Request body did not contain valid form values"},
+				))
+			defer RecoverResponse(w, r)
+			panic("Failed to parse request body contents as form values")
+		}
+
+		log.Printf("Request form decoding completed, updating the request context with the resulting data")
+		next.ServeHTTP(w, r.WithContext(
+			context.WithValue(
+				r.Context(),
+				DECODED_FORM_BODY,
+				decodedForm,
+			)))
+	}
+}
+
+func ExtractQueryParameters(next http.HandlerFunc, required ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryParamMap := make(map[string]string)
+
+		for name, values := range r.URL.Query() {
+			if len(values) > 0 {
+				queryParamMap[name] = values[0]
+			}
+		}
+
+		for _, p := range required {
+			if queryParamMap[p] == "" {
+				log.Printf("Expected a query value for %s but found none", p)
+				r = r.WithContext(
+					context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+				)
+				r = r.WithContext(
+					context.WithValue(
+						r.Context(),
+						HANDLER_RESPONSE_BODY,
+						model.ErrorResponse{Reason: "QUERY_PARAMETER_NOT_PRESENT", Message: "Required query parameter not present: " + p},
+					))
+				defer RecoverResponse(w, r)
+				panic("Required query parameter not present")
+			}
+		}
+
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				QUERY_VALUE_MAPPING,
+				queryParamMap,
+			))
+		next(w, r)
+	}
+}
+
 func ExtractPathParameters(next http.HandlerFunc, parts ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		pathParamMap := make(map[string]string)
@@ -154,6 +287,12 @@ func ExtractPathParameters(next http.HandlerFunc, parts ...string) http.HandlerF
 	}
 }
 
+// setRetryAfter tells the client in whole seconds when to try again.
+func setRetryAfter(w http.ResponseWriter, until time.Time) {
+	seconds := int64(math.Ceil(time.Until(until).Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+}
+
 func EmitResponseAsJSON[ResponseType any](w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Beginning JSON response construction")
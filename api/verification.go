@@ -0,0 +1,211 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/model"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	EMAIL_VERIFICATION_PURPOSE        = "email_verification"
+	EMAIL_VERIFICATION_TOKEN_LIFETIME = 48 * time.Hour
+)
+
+var errVerificationTokenInvalid = errors.New("email verification token is invalid")
+
+type emailVerificationClaims struct {
+	jwt.Claims
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+}
+
+// emailVerificationAudience keeps verification tokens from being accepted anywhere an access token is expected.
+func (provider *TournabyteIdentityProviderService) emailVerificationAudience() jwt.Audience {
+	return jwt.Audience{provider.tokenIssuer() + "/accounts/verify-email"}
+}
+
+func (provider *TournabyteIdentityProviderService) makeEmailVerificationToken(acc *model.Account) (string, error) {
+	cl := emailVerificationClaims{
+		Claims: jwt.Claims{
+			Issuer:   provider.tokenIssuer(),
+			Subject:  acc.Id.Hex(),
+			Audience: provider.emailVerificationAudience(),
+			Expiry:   jwt.NewNumericDate(time.Now().Add(EMAIL_VERIFICATION_TOKEN_LIFETIME)),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ID:       generateOpaqueToken(TOKEN_ID_BYTES),
+		},
+		Purpose: EMAIL_VERIFICATION_PURPOSE,
+		Email:   acc.Email,
+	}
+	return jwt.Signed(provider.sessionTokenSigner).Claims(cl).Serialize()
+}
+
+func (provider *TournabyteIdentityProviderService) parseEmailVerificationToken(raw string, idHex string) (*emailVerificationClaims, error) {
+	var claims emailVerificationClaims
+
+	if parseErr := provider.parseSignedToken(raw, &claims); parseErr != nil {
+		return nil, parseErr
+	}
+	expected := jwt.Expected{
+		Issuer:      provider.tokenIssuer(),
+		Subject:     idHex,
+		AnyAudience: provider.emailVerificationAudience(),
+		Time:        time.Now(),
+	}
+	if validateErr := claims.ValidateWithLeeway(expected, provider.tokens.leeway); validateErr != nil {
+		return nil, validateErr
+	}
+	if claims.Purpose != EMAIL_VERIFICATION_PURPOSE || claims.ID == "" || claims.Expiry == nil {
+		return nil, errVerificationTokenInvalid
+	}
+	return &claims, nil
+}
+
+// sendEmailVerification issues a verification token for the current email of the account and delivers it.
+// Failures are logged rather than surfaced since the player can always request another token.
+func (provider *TournabyteIdentityProviderService) sendEmailVerification(ctx context.Context, acc *model.Account) {
+	token, tokenErr := provider.makeEmailVerificationToken(acc)
+	if tokenErr != nil {
+		log.Printf("Could not issue an email verification token for account %s: %v", acc.Id.Hex(), tokenErr)
+		return
+	}
+	if deliverErr := provider.deliverEmailVerification(ctx, acc, token); deliverErr != nil {
+		log.Printf("Could not deliver the email verification token for account %s: %v", acc.Id.Hex(), deliverErr)
+	}
+}
+
+func (provider *TournabyteIdentityProviderService) deliverEmailVerification(ctx context.Context, acc *model.Account, token string) error {
+	return provider.sendMail(ctx, EMAIL_VERIFICATION_TEMPLATE, acc, mailData{
+		Email:       acc.Email,
+		DisplayName: acc.DisplayName,
+		Token:       token,
+		Link:        provider.tokenIssuer() + "/accounts/" + acc.Id.Hex() + "/verify-email",
+		ExpiresIn:   formatLifetime(EMAIL_VERIFICATION_TOKEN_LIFETIME),
+	})
+}
+
+func (provider *TournabyteIdentityProviderService) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+	request, _ := r.Context().Value(DECODED_JSON_BODY).(model.EmailVerificationRequest)
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	claims, parseErr := provider.parseEmailVerificationToken(request.Token, idHex)
+	var acc *model.Account
+	if parseErr == nil {
+		acc, parseErr = accountsCollectionHandle.FindById(r.Context(), idHex)
+		if parseErr == nil && acc.Email != claims.Email {
+			parseErr = errVerificationTokenInvalid
+		}
+	}
+	if parseErr != nil {
+		log.Printf("Rejecting email verification for account %s: %v", idHex, parseErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "VERIFICATION_TOKEN_INVALID", Message: "Verification token is invalid or expired"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Invalid email verification token")
+	}
+
+	revokedTokensCollectionHandle := model.NewTournabyteRevokedTokenRepository(
+		provider.collection("revoked_tokens"),
+	)
+	firstUse, consumeErr := revokedTokensCollectionHandle.Consume(r.Context(), claims.ID, claims.Expiry.Time())
+	if consumeErr == nil && !firstUse {
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "VERIFICATION_TOKEN_USED", Message: "Verification token was already used"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Email verification token replayed")
+	}
+	if consumeErr == nil {
+		consumeErr = accountsCollectionHandle.MarkEmailVerified(r.Context(), acc.Id, claims.Email)
+	}
+
+	switch {
+	case errors.Is(consumeErr, mongo.ErrNoDocuments):
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusBadRequest),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "VERIFICATION_TOKEN_INVALID", Message: "Verification token is invalid or expired"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Email changed during verification")
+
+	case consumeErr != nil:
+		log.Printf("Failed to verify the email of account %s: %v", idHex, consumeErr)
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusInternalServerError),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				model.ErrorResponse{Reason: "EMAIL_NOT_VERIFIED", Message: "Email address could not be verified"},
+			))
+		defer RecoverResponse(w, r)
+		panic("Email verification failed")
+
+	default:
+		acc.EmailVerified = true
+		r = r.WithContext(
+			context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusOK),
+		)
+		r = r.WithContext(
+			context.WithValue(
+				r.Context(),
+				HANDLER_RESPONSE_BODY,
+				acc.BasicInfo(),
+			))
+		EmitResponseAsJSON[model.BasicAccountInfoResponse](w, r)
+	}
+}
+
+// resendEmailVerification answers identically whether or not a token was sent so it reveals nothing about the account.
+func (provider *TournabyteIdentityProviderService) resendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	idHex := r.Context().Value(PATH_VALUE_MAPPING).(map[string]string)["id"]
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	if acc, findErr := accountsCollectionHandle.FindById(r.Context(), idHex); findErr == nil && !acc.EmailVerified {
+		provider.sendEmailVerification(r.Context(), acc)
+	}
+
+	r = r.WithContext(
+		context.WithValue(r.Context(), HANDLER_STATUS_CODE, http.StatusAccepted),
+	)
+	r = r.WithContext(
+		context.WithValue(
+			r.Context(),
+			HANDLER_RESPONSE_BODY,
+			struct{}{},
+		))
+	EmitResponseAsJSON[struct{}](w, r)
+}
@@ -0,0 +1,86 @@
+/*
+ * package api defines the server net/http server instance used for processing requests for idp service
+ */
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/tournabyte/idp/model"
+)
+
+var (
+	errTokenMalformed  = errors.New("token is malformed")
+	errTokenUnknownKey = errors.New("token was signed by an unknown key")
+	errTokenRevoked    = errors.New("token has been revoked")
+	errAccountInactive = errors.New("token subject is not an active account")
+)
+
+func (provider *TournabyteIdentityProviderService) parseSignedToken(raw string, claims any) error {
+	token, parseErr := jwt.ParseSigned(raw, SUPPORTED_SIGNING_ALGORITHMS)
+	if parseErr != nil || len(token.Headers) != 1 {
+		return errTokenMalformed
+	}
+
+	key, found := provider.signingKeys.VerificationKey(token.Headers[0].KeyID)
+	if !found || key.Algorithm != token.Headers[0].Algorithm {
+		return errTokenUnknownKey
+	}
+
+	if claimsErr := token.Claims(key.Key, claims); claimsErr != nil {
+		return fmt.Errorf("%w: %v", errTokenMalformed, claimsErr)
+	}
+	return nil
+}
+
+func (provider *TournabyteIdentityProviderService) parseAccessToken(raw string) (*accessTokenClaims, error) {
+	var claims accessTokenClaims
+
+	if parseErr := provider.parseSignedToken(raw, &claims); parseErr != nil {
+		return nil, parseErr
+	}
+	return &claims, nil
+}
+
+func (provider *TournabyteIdentityProviderService) verifyAccessToken(ctx context.Context, raw string) (*accessTokenClaims, *model.Account, error) {
+	claims, parseErr := provider.parseAccessToken(raw)
+	if parseErr != nil {
+		return nil, nil, parseErr
+	}
+
+	expected := jwt.Expected{
+		Issuer:      provider.tokenIssuer(),
+		AnyAudience: provider.tokens.acceptedAudiences(),
+		Time:        time.Now(),
+	}
+	if validateErr := claims.ValidateWithLeeway(expected, provider.tokens.leeway); validateErr != nil {
+		return nil, nil, validateErr
+	}
+
+	revokedTokensCollectionHandle := model.NewTournabyteRevokedTokenRepository(
+		provider.collection("revoked_tokens"),
+	)
+	revoked, lookupErr := revokedTokensCollectionHandle.IsRevoked(ctx, claims.ID)
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	}
+	if revoked {
+		return nil, nil, errTokenRevoked
+	}
+
+	accountsCollectionHandle := model.NewTournabyteAccountRepository(
+		provider.collection("accounts"),
+	)
+	acc, findErr := accountsCollectionHandle.FindById(ctx, claims.Subject)
+	if findErr != nil || !acc.Active {
+		return nil, nil, errAccountInactive
+	}
+	if claims.IssuedAt != nil && acc.IssuedBeforeRevocation(claims.IssuedAt.Time()) {
+		return nil, nil, errTokenRevoked
+	}
+	return claims, acc, nil
+}
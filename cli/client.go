@@ -0,0 +1,67 @@
+/*
+ * package cli defines the command line interface (CLI) for the Tournabyte identity provider service
+ */
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tournabyte/idp/api"
+)
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Manage OAuth clients registered with the IdP",
+}
+
+var clientRegisterCmd = &cobra.Command{
+	Use:   "register",
+	Short: "Register a new OAuth client and print its credentials",
+	Run:   doRegisterClient,
+}
+
+func init() {
+	clientRegisterCmd.Flags().String("name", "", "Human readable name of the client")
+	clientRegisterCmd.Flags().StringSlice("redirect-uri", nil, "Comma-separated list of redirect URIs the client may use")
+	clientRegisterCmd.Flags().StringSlice("scope", []string{"openid"}, "Comma-separated list of scopes the client may request")
+	clientRegisterCmd.Flags().Bool("public", false, "Register a public client (no client secret, e.g. mobile or browser apps)")
+	clientRegisterCmd.MarkFlagRequired("name")
+	clientRegisterCmd.MarkFlagRequired("redirect-uri")
+
+	clientCmd.AddCommand(clientRegisterCmd)
+	rootCmd.AddCommand(clientCmd)
+}
+
+func doRegisterClient(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	redirectURIs, _ := cmd.Flags().GetStringSlice("redirect-uri")
+	scopes, _ := cmd.Flags().GetStringSlice("scope")
+	public, _ := cmd.Flags().GetBool("public")
+
+	opts, err := appConf.GetOptions()
+	if err != nil {
+		log.Fatalf("Application options could not be retrieved: %v", err)
+	}
+
+	server, err := api.NewIdentityProviderServer(opts)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, secret, err := server.RegisterClient(ctx, name, redirectURIs, scopes, public)
+	if err != nil {
+		log.Fatalf("Failed to register client: %v", err)
+	}
+
+	fmt.Printf("client_id: %s\n", client.Id)
+	if secret != "" {
+		fmt.Printf("client_secret: %s\n", secret)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * package cli defines the command line interface (CLI) for the Tournabyte identity provider service
+ */
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tournabyte/idp/api"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys used to sign tokens",
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Publish a freshly generated signing key and switch to it once verifiers have fetched it",
+	Run:   doRotateKeys,
+}
+
+func init() {
+	keysCmd.AddCommand(keysRotateCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+func doRotateKeys(cmd *cobra.Command, args []string) {
+	opts, err := appConf.GetOptions()
+	if err != nil {
+		log.Fatalf("Application options could not be retrieved: %v", err)
+	}
+
+	server, err := api.NewIdentityProviderServer(opts)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	kid, activatesAt, err := server.RotateSigningKey(ctx)
+	if err != nil {
+		log.Fatalf("Failed to rotate signing key: %v", err)
+	}
+
+	fmt.Printf("next kid: %s (signing from %s)\n", kid, activatesAt.Format(time.RFC3339))
+}
@@ -18,6 +18,14 @@ var rootCmd = &cobra.Command{
 
 var appConf *model.ApplicationConfiguration = model.NewApplicationConfiguration("json", "appconf", []string{"/etc/tournabyte/idp", "$HOME/.local/tournabyte/idp", "."})
 
+// redacted hides secrets in the configuration dump while still showing whether they are set.
+func redacted(value any) string {
+	if value == nil || value == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
 func Execute() {
 	rootCmd.Execute()
 }
@@ -30,9 +38,55 @@ func getConfig(cmd *cobra.Command, args []string) {
 	}
 	log.Printf("Application configuration after reading config files")
 	log.Printf("\tserve.port: %v", appConf.GetValue("serve.port"))
-	log.Printf("\tserve.jwt.key: %v", appConf.GetValue("serve.jwt.key"))
+	log.Printf("\tserve.jwt.key: %v", redacted(appConf.GetValue("serve.jwt.key")))
 	log.Printf("\tserve.jwt.leeway: %v", appConf.GetValue("serve.jwt.leeway"))
+	log.Printf("\tserve.jwt.algorithm: %v", appConf.GetValue("serve.jwt.algorithm"))
+	log.Printf("\tserve.jwt.keyfile: %v", appConf.GetValue("serve.jwt.keyfile"))
+	log.Printf("\tserve.jwt.rotation: %v", appConf.GetValue("serve.jwt.rotation"))
+	log.Printf("\tserve.jwt.retention: %v", appConf.GetValue("serve.jwt.retention"))
+	log.Printf("\tserve.jwt.publish_delay: %v", appConf.GetValue("serve.jwt.publish_delay"))
+	log.Printf("\tserve.jwt.key_encryption_key: %v", redacted(appConf.GetValue("serve.jwt.key_encryption_key")))
+	log.Printf("\tserve.jwt.plaintext_keys: %v", appConf.GetValue("serve.jwt.plaintext_keys"))
+	log.Printf("\tserve.jwt.issuer: %v", appConf.GetValue("serve.jwt.issuer"))
+	log.Printf("\tserve.jwt.audience: %v", appConf.GetValue("serve.jwt.audience"))
+	log.Printf("\tserve.jwt.clients: %v", appConf.GetValue("serve.jwt.clients"))
+	log.Printf("\tserve.jwt.access_ttl: %v", appConf.GetValue("serve.jwt.access_ttl"))
+	log.Printf("\tserve.jwt.refresh_ttl: %v", appConf.GetValue("serve.jwt.refresh_ttl"))
+	log.Printf("\taccounts.purge_grace: %v", appConf.GetValue("accounts.purge_grace"))
+	log.Printf("\taccounts.require_verified_email: %v", appConf.GetValue("accounts.require_verified_email"))
+	log.Printf("\taccounts.password.min_length: %v", appConf.GetValue("accounts.password.min_length"))
+	log.Printf("\taccounts.password.max_length: %v", appConf.GetValue("accounts.password.max_length"))
+	log.Printf("\taccounts.password.require_lowercase: %v", appConf.GetValue("accounts.password.require_lowercase"))
+	log.Printf("\taccounts.password.require_uppercase: %v", appConf.GetValue("accounts.password.require_uppercase"))
+	log.Printf("\taccounts.password.require_digit: %v", appConf.GetValue("accounts.password.require_digit"))
+	log.Printf("\taccounts.password.require_symbol: %v", appConf.GetValue("accounts.password.require_symbol"))
+	log.Printf("\taccounts.password.allow_email_derived: %v", appConf.GetValue("accounts.password.allow_email_derived"))
+	log.Printf("\taccounts.password.breached_list: %v", appConf.GetValue("accounts.password.breached_list"))
+	log.Printf("\taccounts.lockout.threshold: %v", appConf.GetValue("accounts.lockout.threshold"))
+	log.Printf("\taccounts.lockout.base_delay: %v", appConf.GetValue("accounts.lockout.base_delay"))
+	log.Printf("\taccounts.lockout.max_delay: %v", appConf.GetValue("accounts.lockout.max_delay"))
+	log.Printf("\taccounts.lockout.reset_after: %v", appConf.GetValue("accounts.lockout.reset_after"))
+	log.Printf("\taccounts.mfa.issuer: %v", appConf.GetValue("accounts.mfa.issuer"))
+	log.Printf("\taccounts.mfa.skew: %v", appConf.GetValue("accounts.mfa.skew"))
+	log.Printf("\taccounts.mfa.challenge_ttl: %v", appConf.GetValue("accounts.mfa.challenge_ttl"))
+	log.Printf("\tmail.driver: %v", appConf.GetValue("mail.driver"))
+	log.Printf("\tmail.from: %v", appConf.GetValue("mail.from"))
+	log.Printf("\tmail.templates: %v", appConf.GetValue("mail.templates"))
+	log.Printf("\tmail.directory: %v", appConf.GetValue("mail.directory"))
+	log.Printf("\tmail.smtp.host: %v", appConf.GetValue("mail.smtp.host"))
+	log.Printf("\tmail.smtp.port: %v", appConf.GetValue("mail.smtp.port"))
+	log.Printf("\tmail.smtp.username: %v", appConf.GetValue("mail.smtp.username"))
+	log.Printf("\tmail.smtp.password: %v", redacted(appConf.GetValue("mail.smtp.password")))
+	log.Printf("\tmail.smtp.starttls: %v", appConf.GetValue("mail.smtp.starttls"))
+	log.Printf("\tratelimit.disabled: %v", appConf.GetValue("ratelimit.disabled"))
+	log.Printf("\tratelimit.store: %v", appConf.GetValue("ratelimit.store"))
+	log.Printf("\tratelimit.trusted_proxies: %v", appConf.GetValue("ratelimit.trusted_proxies"))
+	log.Printf("\tratelimit.proxy_headers: %v", appConf.GetValue("ratelimit.proxy_headers"))
+	log.Printf("\tratelimit.per_ip.requests: %v", appConf.GetValue("ratelimit.per_ip.requests"))
+	log.Printf("\tratelimit.per_ip.period: %v", appConf.GetValue("ratelimit.per_ip.period"))
+	log.Printf("\tratelimit.per_email.requests: %v", appConf.GetValue("ratelimit.per_email.requests"))
+	log.Printf("\tratelimit.per_email.period: %v", appConf.GetValue("ratelimit.per_email.period"))
 	log.Printf("\tdatastore.hosts: %v", appConf.GetValue("datastore.hosts"))
 	log.Printf("\tdatastore.username: %v", appConf.GetValue("datastore.username"))
-	log.Printf("\tdatastore.password: %v", appConf.GetValue("datastore.password"))
+	log.Printf("\tdatastore.password: %v", redacted(appConf.GetValue("datastore.password")))
 }
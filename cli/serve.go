@@ -37,11 +37,57 @@ func init() {
 
 	log.Printf("Application configuration after applying plags")
 	log.Printf("\tserve.port: %v", appConf.GetValue("serve.port"))
-	log.Printf("\tserve.jwt.key: %v", appConf.GetValue("serve.jwt.key"))
+	log.Printf("\tserve.jwt.key: %v", redacted(appConf.GetValue("serve.jwt.key")))
 	log.Printf("\tserve.jwt.leeway: %v", appConf.GetValue("serve.jwt.leeway"))
+	log.Printf("\tserve.jwt.algorithm: %v", appConf.GetValue("serve.jwt.algorithm"))
+	log.Printf("\tserve.jwt.keyfile: %v", appConf.GetValue("serve.jwt.keyfile"))
+	log.Printf("\tserve.jwt.rotation: %v", appConf.GetValue("serve.jwt.rotation"))
+	log.Printf("\tserve.jwt.retention: %v", appConf.GetValue("serve.jwt.retention"))
+	log.Printf("\tserve.jwt.publish_delay: %v", appConf.GetValue("serve.jwt.publish_delay"))
+	log.Printf("\tserve.jwt.key_encryption_key: %v", redacted(appConf.GetValue("serve.jwt.key_encryption_key")))
+	log.Printf("\tserve.jwt.plaintext_keys: %v", appConf.GetValue("serve.jwt.plaintext_keys"))
+	log.Printf("\tserve.jwt.issuer: %v", appConf.GetValue("serve.jwt.issuer"))
+	log.Printf("\tserve.jwt.audience: %v", appConf.GetValue("serve.jwt.audience"))
+	log.Printf("\tserve.jwt.clients: %v", appConf.GetValue("serve.jwt.clients"))
+	log.Printf("\tserve.jwt.access_ttl: %v", appConf.GetValue("serve.jwt.access_ttl"))
+	log.Printf("\tserve.jwt.refresh_ttl: %v", appConf.GetValue("serve.jwt.refresh_ttl"))
+	log.Printf("\taccounts.purge_grace: %v", appConf.GetValue("accounts.purge_grace"))
+	log.Printf("\taccounts.require_verified_email: %v", appConf.GetValue("accounts.require_verified_email"))
+	log.Printf("\taccounts.password.min_length: %v", appConf.GetValue("accounts.password.min_length"))
+	log.Printf("\taccounts.password.max_length: %v", appConf.GetValue("accounts.password.max_length"))
+	log.Printf("\taccounts.password.require_lowercase: %v", appConf.GetValue("accounts.password.require_lowercase"))
+	log.Printf("\taccounts.password.require_uppercase: %v", appConf.GetValue("accounts.password.require_uppercase"))
+	log.Printf("\taccounts.password.require_digit: %v", appConf.GetValue("accounts.password.require_digit"))
+	log.Printf("\taccounts.password.require_symbol: %v", appConf.GetValue("accounts.password.require_symbol"))
+	log.Printf("\taccounts.password.allow_email_derived: %v", appConf.GetValue("accounts.password.allow_email_derived"))
+	log.Printf("\taccounts.password.breached_list: %v", appConf.GetValue("accounts.password.breached_list"))
+	log.Printf("\taccounts.lockout.threshold: %v", appConf.GetValue("accounts.lockout.threshold"))
+	log.Printf("\taccounts.lockout.base_delay: %v", appConf.GetValue("accounts.lockout.base_delay"))
+	log.Printf("\taccounts.lockout.max_delay: %v", appConf.GetValue("accounts.lockout.max_delay"))
+	log.Printf("\taccounts.lockout.reset_after: %v", appConf.GetValue("accounts.lockout.reset_after"))
+	log.Printf("\taccounts.mfa.issuer: %v", appConf.GetValue("accounts.mfa.issuer"))
+	log.Printf("\taccounts.mfa.skew: %v", appConf.GetValue("accounts.mfa.skew"))
+	log.Printf("\taccounts.mfa.challenge_ttl: %v", appConf.GetValue("accounts.mfa.challenge_ttl"))
+	log.Printf("\tmail.driver: %v", appConf.GetValue("mail.driver"))
+	log.Printf("\tmail.from: %v", appConf.GetValue("mail.from"))
+	log.Printf("\tmail.templates: %v", appConf.GetValue("mail.templates"))
+	log.Printf("\tmail.directory: %v", appConf.GetValue("mail.directory"))
+	log.Printf("\tmail.smtp.host: %v", appConf.GetValue("mail.smtp.host"))
+	log.Printf("\tmail.smtp.port: %v", appConf.GetValue("mail.smtp.port"))
+	log.Printf("\tmail.smtp.username: %v", appConf.GetValue("mail.smtp.username"))
+	log.Printf("\tmail.smtp.password: %v", redacted(appConf.GetValue("mail.smtp.password")))
+	log.Printf("\tmail.smtp.starttls: %v", appConf.GetValue("mail.smtp.starttls"))
+	log.Printf("\tratelimit.disabled: %v", appConf.GetValue("ratelimit.disabled"))
+	log.Printf("\tratelimit.store: %v", appConf.GetValue("ratelimit.store"))
+	log.Printf("\tratelimit.trusted_proxies: %v", appConf.GetValue("ratelimit.trusted_proxies"))
+	log.Printf("\tratelimit.proxy_headers: %v", appConf.GetValue("ratelimit.proxy_headers"))
+	log.Printf("\tratelimit.per_ip.requests: %v", appConf.GetValue("ratelimit.per_ip.requests"))
+	log.Printf("\tratelimit.per_ip.period: %v", appConf.GetValue("ratelimit.per_ip.period"))
+	log.Printf("\tratelimit.per_email.requests: %v", appConf.GetValue("ratelimit.per_email.requests"))
+	log.Printf("\tratelimit.per_email.period: %v", appConf.GetValue("ratelimit.per_email.period"))
 	log.Printf("\tdatastore.hosts: %v", appConf.GetValue("datastore.hosts"))
 	log.Printf("\tdatastore.username: %v", appConf.GetValue("datastore.username"))
-	log.Printf("\tdatastore.password: %v", appConf.GetValue("datastore.password"))
+	log.Printf("\tdatastore.password: %v", redacted(appConf.GetValue("datastore.password")))
 
 }
 
@@ -52,11 +98,57 @@ func doCommand(cmd *cobra.Command, args []string) {
 	} else {
 		log.Printf("Application Options:\n")
 		log.Printf("\tServe.Port = %d", opts.Serve.Port)
-		log.Printf("\tServe.WebToken.Key = %s", opts.Serve.WebToken.Key)
+		log.Printf("\tServe.WebToken.Key = %s", redacted(opts.Serve.WebToken.Key))
 		log.Printf("\tServe.WebToken.Leeway = %s", opts.Serve.WebToken.Leeway.String())
+		log.Printf("\tServe.WebToken.Algorithm = %s", opts.Serve.WebToken.Algorithm)
+		log.Printf("\tServe.WebToken.KeyFile = %s", opts.Serve.WebToken.KeyFile)
+		log.Printf("\tServe.WebToken.RotationInterval = %s", opts.Serve.WebToken.RotationInterval.String())
+		log.Printf("\tServe.WebToken.KeyRetention = %s", opts.Serve.WebToken.KeyRetention.String())
+		log.Printf("\tServe.WebToken.PublishDelay = %s", opts.Serve.WebToken.PublishDelay.String())
+		log.Printf("\tServe.WebToken.KeyEncryptionKey = %s", redacted(opts.Serve.WebToken.KeyEncryptionKey))
+		log.Printf("\tServe.WebToken.PlaintextKeys = %t", opts.Serve.WebToken.PlaintextKeys)
+		log.Printf("\tServe.WebToken.Issuer = %s", opts.Serve.WebToken.Issuer)
+		log.Printf("\tServe.WebToken.Audience = %s", opts.Serve.WebToken.Audience)
+		log.Printf("\tServe.WebToken.ClientAudiences = %v", opts.Serve.WebToken.ClientAudiences)
+		log.Printf("\tServe.WebToken.AccessTokenTTL = %s", opts.Serve.WebToken.AccessTokenTTL.String())
+		log.Printf("\tServe.WebToken.RefreshTokenTTL = %s", opts.Serve.WebToken.RefreshTokenTTL.String())
+		log.Printf("\tAccounts.PurgeGracePeriod = %s", opts.Accounts.PurgeGracePeriod.String())
+		log.Printf("\tAccounts.RequireVerifiedEmail = %t", opts.Accounts.RequireVerifiedEmail)
+		log.Printf("\tAccounts.Password.MinLength = %d", opts.Accounts.Password.MinLength)
+		log.Printf("\tAccounts.Password.MaxLength = %d", opts.Accounts.Password.MaxLength)
+		log.Printf("\tAccounts.Password.RequireLowercase = %t", opts.Accounts.Password.RequireLowercase)
+		log.Printf("\tAccounts.Password.RequireUppercase = %t", opts.Accounts.Password.RequireUppercase)
+		log.Printf("\tAccounts.Password.RequireDigit = %t", opts.Accounts.Password.RequireDigit)
+		log.Printf("\tAccounts.Password.RequireSymbol = %t", opts.Accounts.Password.RequireSymbol)
+		log.Printf("\tAccounts.Password.AllowEmailDerived = %t", opts.Accounts.Password.AllowEmailDerived)
+		log.Printf("\tAccounts.Password.BreachedList = %s", opts.Accounts.Password.BreachedList)
+		log.Printf("\tAccounts.Lockout.Threshold = %d", opts.Accounts.Lockout.Threshold)
+		log.Printf("\tAccounts.Lockout.BaseDelay = %s", opts.Accounts.Lockout.BaseDelay.String())
+		log.Printf("\tAccounts.Lockout.MaxDelay = %s", opts.Accounts.Lockout.MaxDelay.String())
+		log.Printf("\tAccounts.Lockout.ResetAfter = %s", opts.Accounts.Lockout.ResetAfter.String())
+		log.Printf("\tAccounts.MFA.Issuer = %s", opts.Accounts.MFA.Issuer)
+		log.Printf("\tAccounts.MFA.Skew = %d", opts.Accounts.MFA.Skew)
+		log.Printf("\tAccounts.MFA.ChallengeTTL = %s", opts.Accounts.MFA.ChallengeTTL.String())
+		log.Printf("\tMail.Driver = %s", opts.Mail.Driver)
+		log.Printf("\tMail.From = %s", opts.Mail.From)
+		log.Printf("\tMail.Templates = %s", opts.Mail.Templates)
+		log.Printf("\tMail.Directory = %s", opts.Mail.Directory)
+		log.Printf("\tMail.SMTP.Host = %s", opts.Mail.SMTP.Host)
+		log.Printf("\tMail.SMTP.Port = %d", opts.Mail.SMTP.Port)
+		log.Printf("\tMail.SMTP.Username = %s", opts.Mail.SMTP.Username)
+		log.Printf("\tMail.SMTP.Password = %s", redacted(opts.Mail.SMTP.Password))
+		log.Printf("\tMail.SMTP.StartTLS = %t", opts.Mail.SMTP.StartTLS)
+		log.Printf("\tRateLimit.Disabled = %t", opts.RateLimit.Disabled)
+		log.Printf("\tRateLimit.Store = %s", opts.RateLimit.Store)
+		log.Printf("\tRateLimit.TrustedProxies = %v", opts.RateLimit.TrustedProxies)
+		log.Printf("\tRateLimit.ProxyHeaders = %v", opts.RateLimit.ProxyHeaders)
+		log.Printf("\tRateLimit.PerIP.Requests = %d", opts.RateLimit.PerIP.Requests)
+		log.Printf("\tRateLimit.PerIP.Period = %s", opts.RateLimit.PerIP.Period.String())
+		log.Printf("\tRateLimit.PerEmail.Requests = %d", opts.RateLimit.PerEmail.Requests)
+		log.Printf("\tRateLimit.PerEmail.Period = %s", opts.RateLimit.PerEmail.Period.String())
 		log.Printf("\tDatastore.Hosts = %v", opts.Datastore.Hosts)
 		log.Printf("\tDatastore.Username = %v", opts.Datastore.Username)
-		log.Printf("\tDatastore.Password = %v", opts.Datastore.Password)
+		log.Printf("\tDatastore.Password = %v", redacted(opts.Datastore.Password))
 		server, err := api.NewIdentityProviderServer(opts)
 
 		if err != nil {
@@ -0,0 +1,47 @@
+/*
+ * package mailer delivers the outbound email of the Tournabyte identity provider
+ */
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message to its own .eml file in Directory. With Maildir set the directory is
+// treated as a maildir: messages are written under tmp/ and moved into new/ once complete.
+type FileMailer struct {
+	Directory string
+	Maildir   bool
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if !m.Maildir {
+		if err := os.MkdirAll(m.Directory, 0o700); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), randomToken())
+		return os.WriteFile(filepath.Join(m.Directory, name), body, 0o600)
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(m.Directory, sub), 0o700); err != nil {
+			return err
+		}
+	}
+	hostname, _ := os.Hostname()
+	name := fmt.Sprintf("%d.P%d_%s.%s", time.Now().Unix(), os.Getpid(), randomToken(), hostname)
+	tmp := filepath.Join(m.Directory, "tmp", name)
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.Directory, "new", name))
+}
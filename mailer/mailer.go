@@ -0,0 +1,124 @@
+/*
+ * package mailer delivers the outbound email of the Tournabyte identity provider
+ */
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoRecipient = errors.New("mailer: message has no recipient")
+	ErrNoSender    = errors.New("mailer: message has no sender")
+)
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers a composed message. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func (msg Message) validate() error {
+	if msg.From == "" {
+		return ErrNoSender
+	}
+	if len(msg.To) == 0 {
+		return ErrNoRecipient
+	}
+	if _, err := mail.ParseAddress(msg.From); err != nil {
+		return fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+	for _, to := range msg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("mailer: invalid recipient: %w", err)
+		}
+	}
+	return nil
+}
+
+// Bytes renders the message as RFC 5322 text; a message carrying both bodies becomes multipart/alternative.
+func (msg Message) Bytes() ([]byte, error) {
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+randomToken()+"@"+senderDomain(msg.From)+">")
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return buf.Bytes(), writeQuotedPrintable(&buf, msg.Text)
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+parts.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, body := range []struct{ mediaType, content string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body.mediaType + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(part, body.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func senderDomain(from string) string {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, domain, found := strings.Cut(addr.Address, "@"); found {
+			return domain
+		}
+	}
+	return "localhost"
+}
+
+func randomToken() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
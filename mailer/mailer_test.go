@@ -0,0 +1,208 @@
+/*
+ * package mailer delivers the outbound email of the Tournabyte identity provider
+ */
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailerTestSuite struct {
+	suite.Suite
+	msg Message
+}
+
+func TestMailer(t *testing.T) {
+	suite.Run(t, new(MailerTestSuite))
+}
+
+func (s *MailerTestSuite) SetupTest() {
+	s.msg = Message{
+		From:    "Tournabyte <no-reply@tournabyte.io>",
+		To:      []string{"player@example.io"},
+		Subject: "Réinitialiser",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+}
+
+func (s *MailerTestSuite) TestBytesBuildsMultipartAlternative() {
+	raw, err := s.msg.Bytes()
+	s.Require().NoError(err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	s.Require().NoError(err)
+	assert.Equal(s.T(), "player@example.io", parsed.Header.Get("To"))
+	assert.Contains(s.T(), parsed.Header.Get("Content-Type"), "multipart/alternative")
+	assert.Contains(s.T(), parsed.Header.Get("Message-ID"), "@tournabyte.io>")
+	assert.Contains(s.T(), string(raw), "plain body")
+	assert.Contains(s.T(), string(raw), "<p>html body</p>")
+}
+
+func (s *MailerTestSuite) TestBytesRejectsMissingRecipient() {
+	s.msg.To = nil
+
+	_, err := s.msg.Bytes()
+
+	assert.ErrorIs(s.T(), err, ErrNoRecipient)
+}
+
+func (s *MailerTestSuite) TestMemoryMailerCaptures() {
+	m := NewMemoryMailer()
+
+	assert.NoError(s.T(), m.Send(context.TODO(), s.msg))
+	assert.Len(s.T(), m.Messages(), 1)
+	m.Reset()
+	assert.Empty(s.T(), m.Messages())
+}
+
+func (s *MailerTestSuite) TestFileMailerWritesEml() {
+	dir := s.T().TempDir()
+	m := &FileMailer{Directory: dir}
+
+	s.Require().NoError(m.Send(context.TODO(), s.msg))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(s.T(), files, 1)
+}
+
+func (s *MailerTestSuite) TestFileMailerDeliversToMaildirNew() {
+	dir := s.T().TempDir()
+	m := &FileMailer{Directory: dir, Maildir: true}
+
+	s.Require().NoError(m.Send(context.TODO(), s.msg))
+
+	delivered, _ := os.ReadDir(filepath.Join(dir, "new"))
+	pending, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	assert.Len(s.T(), delivered, 1)
+	assert.Empty(s.T(), pending)
+}
+
+func (s *MailerTestSuite) TestTemplatesComposeEscapesHTML() {
+	fsys := fstest.MapFS{
+		"greeting.txt":  {Data: []byte("{{define \"subject\"}}Hi {{.}}{{end}}\nHello {{.}}\n")},
+		"greeting.html": {Data: []byte("<p>Hello {{.}}</p>")},
+	}
+	templates, err := NewTemplates(fsys, ".")
+	s.Require().NoError(err)
+
+	msg, err := templates.Compose("greeting", s.msg.From, s.msg.To, "<b>player</b>")
+
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), "Hi <b>player</b>", msg.Subject)
+		assert.Equal(s.T(), "Hello <b>player</b>\n", msg.Text)
+		assert.Equal(s.T(), "<p>Hello &lt;b&gt;player&lt;/b&gt;</p>", msg.HTML)
+	}
+}
+
+func (s *MailerTestSuite) TestTemplatesRequireSubject() {
+	fsys := fstest.MapFS{"greeting.txt": {Data: []byte("Hello")}}
+
+	_, err := NewTemplates(fsys, ".")
+
+	assert.Error(s.T(), err)
+}
+
+func (s *MailerTestSuite) TestDefaultTemplatesCoverProviderMessages() {
+	templates := DefaultTemplates()
+
+	for _, name := range []string{"email_verification", "password_reset"} {
+		msg, err := templates.Compose(name, s.msg.From, s.msg.To, map[string]string{"Email": "player@example.io", "Token": "tok", "Link": "https://idp", "ExpiresIn": "1 hour"})
+		if assert.NoError(s.T(), err, name) {
+			assert.NotEmpty(s.T(), msg.Subject)
+			assert.Contains(s.T(), msg.Text, "tok")
+			assert.Contains(s.T(), msg.HTML, "tok")
+		}
+	}
+}
+
+func (s *MailerTestSuite) TestSMTPMailerRequiresStartTLS() {
+	addr, transcript := fakeSMTPServer(s.T())
+	host, portText, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portText)
+	m := &SMTPMailer{Host: host, Port: port, StartTLS: true}
+
+	err := m.Send(context.TODO(), s.msg)
+
+	assert.ErrorIs(s.T(), err, ErrStartTLSUnsupported)
+	<-transcript
+}
+
+func (s *MailerTestSuite) TestSMTPMailerSubmitsMessage() {
+	addr, transcript := fakeSMTPServer(s.T())
+	host, portText, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portText)
+	m := &SMTPMailer{Host: host, Port: port}
+
+	s.Require().NoError(m.Send(context.TODO(), s.msg))
+
+	session := <-transcript
+	assert.Contains(s.T(), session, "MAIL FROM:<no-reply@tournabyte.io>")
+	assert.Contains(s.T(), session, "RCPT TO:<player@example.io>")
+	assert.Contains(s.T(), session, "plain body")
+}
+
+// fakeSMTPServer accepts one session without STARTTLS support and reports what the client sent.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	transcript := make(chan string, 1)
+	go func() {
+		var session strings.Builder
+		defer func() { transcript <- session.String() }()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ready")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			session.WriteString(line)
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case inData:
+				if command == "." {
+					inData = false
+					reply("250 queued")
+				}
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case command == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), transcript
+}
@@ -0,0 +1,93 @@
+/*
+ * package mailer delivers the outbound email of the Tournabyte identity provider
+ */
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+var ErrStartTLSUnsupported = errors.New("mailer: SMTP server does not offer STARTTLS")
+
+// SMTPMailer submits messages to a relay. With StartTLS set the connection is upgraded before
+// authenticating and delivery fails rather than falling back to plaintext.
+type SMTPMailer struct {
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	StartTLS  bool
+	TLSConfig *tls.Config
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, strconv.Itoa(m.Port)))
+	if err != nil {
+		return fmt.Errorf("mailer: dial: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mailer: greeting: %w", err)
+	}
+	defer client.Close()
+
+	if m.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return ErrStartTLSUnsupported
+		}
+		config := m.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: m.Host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("mailer: starttls: %w", err)
+		}
+	}
+
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("mailer: auth: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(msg.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mailer: mail from: %w", err)
+	}
+	for _, to := range msg.To {
+		rcpt, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("mailer: rcpt to: %w", err)
+		}
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: data: %w", err)
+	}
+	if _, err := data.Write(body); err != nil {
+		return fmt.Errorf("mailer: data: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("mailer: data: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,96 @@
+/*
+ * package mailer delivers the outbound email of the Tournabyte identity provider
+ */
+package mailer
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+const SUBJECT_TEMPLATE = "subject"
+
+//go:embed templates/*.txt templates/*.html
+var defaultTemplates embed.FS
+
+type messageTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders named messages. Every message <name> needs a <name>.txt text/template defining a
+// "subject" template alongside the plain text body, and may have a <name>.html html/template body.
+type Templates struct {
+	messages map[string]messageTemplate
+}
+
+func DefaultTemplates() *Templates {
+	templates, err := NewTemplates(defaultTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+func NewTemplates(fsys fs.FS, dir string) (*Templates, error) {
+	textFiles, err := fs.Glob(fsys, path.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := Templates{messages: map[string]messageTemplate{}}
+	for _, textFile := range textFiles {
+		name := strings.TrimSuffix(path.Base(textFile), ".txt")
+
+		var tmpl messageTemplate
+		if tmpl.text, err = texttemplate.ParseFS(fsys, textFile); err != nil {
+			return nil, err
+		}
+		if tmpl.text.Lookup(SUBJECT_TEMPLATE) == nil {
+			return nil, fmt.Errorf("mailer: template %s defines no %q", textFile, SUBJECT_TEMPLATE)
+		}
+
+		htmlFile := path.Join(dir, name+".html")
+		if _, statErr := fs.Stat(fsys, htmlFile); statErr == nil {
+			if tmpl.html, err = htmltemplate.ParseFS(fsys, htmlFile); err != nil {
+				return nil, err
+			}
+		}
+		templates.messages[name] = tmpl
+	}
+	return &templates, nil
+}
+
+// Compose renders the named message for the given recipients.
+func (t *Templates) Compose(name string, from string, to []string, data any) (Message, error) {
+	msg := Message{From: from, To: to}
+
+	tmpl, found := t.messages[name]
+	if !found {
+		return msg, fmt.Errorf("mailer: no template named %q", name)
+	}
+
+	var subject, text strings.Builder
+	if err := tmpl.text.ExecuteTemplate(&subject, SUBJECT_TEMPLATE, data); err != nil {
+		return msg, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.Join(strings.Fields(subject.String()), " ")
+	msg.Text = strings.TrimLeft(text.String(), "\r\n")
+
+	if tmpl.html != nil {
+		var html strings.Builder
+		if err := tmpl.html.Execute(&html, data); err != nil {
+			return msg, err
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
@@ -6,6 +6,9 @@ package model
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -13,14 +16,86 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+const (
+	DEFAULT_LOCKOUT_THRESHOLD   = 5
+	DEFAULT_LOCKOUT_BASE_DELAY  = 1 * time.Minute
+	DEFAULT_LOCKOUT_MAX_DELAY   = 1 * time.Hour
+	DEFAULT_LOCKOUT_RESET_AFTER = 24 * time.Hour
+)
+
+var (
+	ErrAccountExists    = errors.New("an account with this email already exists")
+	ErrTOTPCodeReused   = errors.New("totp code was already used")
+	ErrMFAEnabled       = errors.New("multi-factor authentication is already enabled")
+	ErrNoTOTPEnrollment = errors.New("no totp enrollment is pending")
+	ErrMFANotEnabled    = errors.New("multi-factor authentication is not enabled")
+	ErrRecoveryCodeUsed = errors.New("recovery code was already used")
+)
+
 type Account struct {
 	Id                            bson.ObjectID `bson:"_id,omitempty"`
 	Email                         string        `bson:"email"`
+	NormalizedEmail               string        `bson:"email_normalized,omitempty"`
+	EmailVerified                 bool          `bson:"email_verified"`
 	Active                        bool          `bson:"active"`
 	CreatedAt                     time.Time     `bson:"created_at"`
 	LastModified                  time.Time     `bson:"modified_at"`
 	LoginKey                      string        `bson:"login_key"`
 	LoginAttemptsSinceLastSuccess int           `bson:"login_attempts"`
+	DisplayName                   string        `bson:"display_name,omitempty"`
+	Roles                         []string      `bson:"roles,omitempty"`
+	Tenant                        string        `bson:"tenant,omitempty"`
+	DeactivatedAt                 time.Time     `bson:"deactivated_at,omitempty"`
+	SessionsValidAfter            time.Time     `bson:"sessions_valid_after,omitempty"`
+	LastFailedLoginAt             time.Time     `bson:"last_failed_login_at,omitempty"`
+	LockedUntil                   time.Time     `bson:"locked_until,omitempty"`
+	MFAEnabled                    bool          `bson:"mfa_enabled,omitempty"`
+	TOTPSecret                    string        `bson:"totp_secret,omitempty" json:"-"`
+	PendingTOTPSecret             string        `bson:"totp_pending_secret,omitempty" json:"-"`
+	LastTOTPStep                  int64         `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes                 []string      `bson:"recovery_codes,omitempty" json:"-"`
+}
+
+// LockoutPolicy locks an account once Threshold failed logins accumulate, for BaseDelay doubling with every
+// further failure up to MaxDelay. Failures are forgotten when none occurred for ResetAfter.
+type LockoutPolicy struct {
+	Threshold  int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	ResetAfter time.Duration
+}
+
+func (p LockoutPolicy) withDefaults() LockoutPolicy {
+	if p.Threshold <= 0 {
+		p.Threshold = DEFAULT_LOCKOUT_THRESHOLD
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DEFAULT_LOCKOUT_BASE_DELAY
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DEFAULT_LOCKOUT_MAX_DELAY
+	}
+	if p.ResetAfter <= 0 {
+		p.ResetAfter = DEFAULT_LOCKOUT_RESET_AFTER
+	}
+	return p
+}
+
+// Delay returns how long an account stays locked after its given number of consecutive failed logins.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	p = p.withDefaults()
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for range failures - p.Threshold {
+		if delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
 }
 
 func (a *Account) BasicInfo() BasicAccountInfoResponse {
@@ -28,12 +103,115 @@ func (a *Account) BasicInfo() BasicAccountInfoResponse {
 
 	info.AccountIdentifier = a.Id
 	info.AccountContact = a.Email
+	info.AccountContactVerified = a.EmailVerified
 	info.AccountCreatedTime = a.CreatedAt
 	info.AccountModifiedAt = a.LastModified
+	info.AccountDisplayName = a.DisplayName
+	info.AccountRoles = a.Roles
+	info.AccountTenant = a.Tenant
+	info.AccountMFAEnabled = a.MFAEnabled
+	info.AccountRecoveryCodesRemaining = a.RemainingRecoveryCodes()
 
 	return info
 }
 
+// AccountChanges lists the document fields an Update sets and removes.
+type AccountChanges struct {
+	Set   bson.D
+	Unset []string
+}
+
+func (a *Account) ListEntry() AccountListEntry {
+	entry := AccountListEntry{
+		BasicAccountInfoResponse: a.BasicInfo(),
+		Active:                   a.Active,
+		Locked:                   a.IsLocked(),
+	}
+	if !a.DeactivatedAt.IsZero() {
+		deactivatedAt := a.DeactivatedAt
+		entry.DeactivatedAt = &deactivatedAt
+	}
+	if entry.Locked {
+		lockedUntil := a.LockedUntil
+		entry.LockedUntil = &lockedUntil
+	}
+	if !a.LastFailedLoginAt.IsZero() {
+		lastFailedLogin := a.LastFailedLoginAt
+		entry.LastFailedLogin = &lastFailedLogin
+	}
+	return entry
+}
+
+// RemainingRecoveryCodes is the number of recovery codes that were not used yet.
+func (a *Account) RemainingRecoveryCodes() int {
+	return len(a.RecoveryCodes)
+}
+
+// IssuedBeforeRevocation reports whether a token issued at issuedAt was cut off by SessionsValidAfter. Token
+// timestamps only have second precision, so a token from the same second as the revocation counts as revoked.
+func (a *Account) IssuedBeforeRevocation(issuedAt time.Time) bool {
+	return !a.SessionsValidAfter.IsZero() && !issuedAt.After(a.SessionsValidAfter)
+}
+
+func (a *Account) IsLocked() bool {
+	return time.Now().Before(a.LockedUntil)
+}
+
+// AccountQuery selects a page of accounts ordered by _id, starting after the After cursor when it is set.
+type AccountQuery struct {
+	After         bson.ObjectID
+	Limit         int
+	Descending    bool
+	EmailPrefix   string
+	Active        *bool
+	Locked        *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// AsOf is the instant the Locked filter is evaluated at, now when unset.
+	AsOf time.Time
+}
+
+func (q AccountQuery) filter() bson.D {
+	filter := bson.D{}
+
+	if !q.After.IsZero() {
+		op := "$gt"
+		if q.Descending {
+			op = "$lt"
+		}
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: op, Value: q.After}}})
+	}
+	if q.EmailPrefix != "" {
+		filter = append(filter, bson.E{Key: "email_normalized", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(normalizeEmailPrefix(q.EmailPrefix))}}})
+	}
+	if q.Active != nil {
+		filter = append(filter, bson.E{Key: "active", Value: *q.Active})
+	}
+	if q.Locked != nil {
+		asOf := q.AsOf
+		if asOf.IsZero() {
+			asOf = time.Now()
+		}
+		lockedFilter := bson.D{{Key: "$gt", Value: asOf.UTC()}}
+		if !*q.Locked {
+			lockedFilter = bson.D{{Key: "$not", Value: lockedFilter}}
+		}
+		filter = append(filter, bson.E{Key: "locked_until", Value: lockedFilter})
+	}
+
+	created := bson.D{}
+	if !q.CreatedAfter.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: q.CreatedAfter.UTC()})
+	}
+	if !q.CreatedBefore.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: q.CreatedBefore.UTC()})
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: created})
+	}
+	return filter
+}
+
 type InsertOneDocumment interface {
 	InsertOne(ctx context.Context, doc any, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error)
 }
@@ -46,26 +224,58 @@ type UpdateOneDocument interface {
 	UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error)
 }
 
+type FindDocuments interface {
+	Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error)
+}
+
+type DeleteOneDocument interface {
+	DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error)
+}
+
 type CreateAndReadAndUpdateOneDocument interface {
 	InsertOneDocumment
 	FindOneDocument
 	UpdateOneDocument
 }
 
+type AccountDocumentOperations interface {
+	CreateAndReadAndUpdateOneDocument
+	DeleteOneDocument
+	FindDocuments
+	FindOneAndUpdateDocument
+}
+
+// DocumentCollection holds every collection operation the repositories rely on; *mongo.Collection implements it.
+type DocumentCollection interface {
+	AccountDocumentOperations
+	UpdateManyDocuments
+}
+
+var _ DocumentCollection = (*mongo.Collection)(nil)
+
 type TournabyteAccountRepository struct {
-	collection CreateAndReadAndUpdateOneDocument
+	collection AccountDocumentOperations
 }
 
-func NewTournabyteAccountRepository(col CreateAndReadAndUpdateOneDocument) *TournabyteAccountRepository {
+func NewTournabyteAccountRepository(col AccountDocumentOperations) *TournabyteAccountRepository {
 	return &TournabyteAccountRepository{collection: col}
 }
 
 func (r *TournabyteAccountRepository) Create(ctx context.Context, account *Account) error {
+	normalized, err := NormalizeEmail(account.Email)
+	if err != nil {
+		return err
+	}
+	account.Email = strings.TrimSpace(account.Email)
+	account.NormalizedEmail = normalized
 	account.Active = true
 	account.CreatedAt = time.Now().UTC()
 	account.LastModified = time.Now().UTC()
 
 	result, err := r.collection.InsertOne(ctx, account)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAccountExists
+	}
 	if err != nil {
 		return err
 	}
@@ -85,7 +295,7 @@ func (r *TournabyteAccountRepository) FindById(ctx context.Context, idHex string
 
 	filter = bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
 	findDocumentErr := r.collection.FindOne(ctx, filter).Decode(&account)
-	if findDocumentErr == mongo.ErrNoDocuments {
+	if findDocumentErr != nil {
 		return nil, findDocumentErr
 	}
 	return &account, nil
@@ -95,30 +305,453 @@ func (r *TournabyteAccountRepository) FindByEmail(ctx context.Context, email str
 	var account Account
 	var filter bson.D
 
-	filter = bson.D{{Key: "email", Value: email}}
+	normalized, normalizeErr := NormalizeEmail(email)
+	if normalizeErr != nil {
+		return nil, normalizeErr
+	}
+
+	filter = bson.D{{Key: "email_normalized", Value: normalized}}
 	findDocumentErr := r.collection.FindOne(ctx, filter).Decode(&account)
 	if findDocumentErr == mongo.ErrNoDocuments {
+		// Accounts the backfill could not normalize are still found by their address as stored.
+		filter = bson.D{{Key: "email", Value: email}, {Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: false}}}}
+		findDocumentErr = r.collection.FindOne(ctx, filter).Decode(&account)
+	}
+	if findDocumentErr != nil {
 		return nil, findDocumentErr
 	}
 	return &account, nil
 }
 
+// BackfillNormalizedEmails sets email_normalized on accounts created before addresses were normalized. Accounts whose
+// address cannot be normalized, or normalizes to the address of another account, are left as they are and returned.
+func (r *TournabyteAccountRepository) BackfillNormalizedEmails(ctx context.Context) (int, []bson.ObjectID, error) {
+	var legacy []Account
+	var skipped []bson.ObjectID
+
+	filter := bson.D{{Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: false}}}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "email", Value: 1}}))
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := cursor.All(ctx, &legacy); err != nil {
+		return 0, nil, err
+	}
+
+	updated := 0
+	for _, acc := range legacy {
+		normalized, err := NormalizeEmail(acc.Email)
+		if err != nil {
+			skipped = append(skipped, acc.Id)
+			continue
+		}
+		// The uniqueness index may not exist yet, so collisions are looked for before writing.
+		var existing Account
+		if err := r.collection.FindOne(ctx, bson.D{{Key: "email_normalized", Value: normalized}}).Decode(&existing); err == nil {
+			skipped = append(skipped, acc.Id)
+			continue
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return updated, skipped, err
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "email_normalized", Value: normalized}}}}
+		result, err := r.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: acc.Id}, filter[0]}, update)
+		if mongo.IsDuplicateKeyError(err) {
+			skipped = append(skipped, acc.Id)
+			continue
+		}
+		if err != nil {
+			return updated, skipped, err
+		}
+		updated += int(result.ModifiedCount)
+	}
+	return updated, skipped, nil
+}
+
+// List returns up to query.Limit accounts and whether more accounts match beyond them.
+func (r *TournabyteAccountRepository) List(ctx context.Context, query AccountQuery) ([]Account, bool, error) {
+	var accounts []Account
+
+	order := 1
+	if query.Descending {
+		order = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: order}}).
+		SetLimit(int64(query.Limit) + 1)
+
+	cursor, findErr := r.collection.Find(ctx, query.filter(), opts)
+	if findErr != nil {
+		return nil, false, findErr
+	}
+	if decodeErr := cursor.All(ctx, &accounts); decodeErr != nil {
+		return nil, false, decodeErr
+	}
+
+	if len(accounts) > query.Limit {
+		return accounts[:query.Limit], true, nil
+	}
+	return accounts, false, nil
+}
+
+func (r *TournabyteAccountRepository) Update(ctx context.Context, id bson.ObjectID, changes AccountChanges) error {
+	var update bson.D
+	var filter bson.D
+
+	set := append(bson.D{}, changes.Set...)
+	for _, field := range changes.Set {
+		if field.Key != "email" {
+			continue
+		}
+		email, _ := field.Value.(string)
+		normalized, err := NormalizeEmail(email)
+		if err != nil {
+			return err
+		}
+		set = append(set, bson.E{Key: "email_normalized", Value: normalized})
+	}
+	set = append(set, bson.E{Key: "modified_at", Value: time.Now().UTC()})
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "active", Value: true}}
+	update = bson.D{{Key: "$set", Value: set}}
+	if len(changes.Unset) > 0 {
+		unset := bson.D{}
+		for _, field := range changes.Unset {
+			unset = append(unset, bson.E{Key: field, Value: ""})
+		}
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAccountExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// MarkEmailVerified marks the email of an active account verified, provided it is still the given address.
+func (r *TournabyteAccountRepository) MarkEmailVerified(ctx context.Context, id bson.ObjectID, email string) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "email", Value: email}, {Key: "active", Value: true}}
+	update = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "email_verified", Value: true},
+		{Key: "modified_at", Value: time.Now().UTC()},
+	}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetLoginKey replaces the password hash of an active account and clears its failed login attempts.
+// Revoking sessions invalidates every access token issued before the change.
+func (r *TournabyteAccountRepository) SetLoginKey(ctx context.Context, id bson.ObjectID, loginKey string, revokeSessions bool) error {
+	var update bson.D
+	var filter bson.D
+
+	now := time.Now().UTC()
+	set := bson.D{
+		{Key: "login_key", Value: loginKey},
+		{Key: "login_attempts", Value: 0},
+		{Key: "modified_at", Value: now},
+	}
+	if revokeSessions {
+		set = append(set, bson.E{Key: "sessions_valid_after", Value: now})
+	}
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "active", Value: true}}
+	update = bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *TournabyteAccountRepository) Deactivate(ctx context.Context, id bson.ObjectID) (time.Time, error) {
+	var update bson.D
+	var filter bson.D
+
+	now := time.Now().UTC()
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "active", Value: true}}
+	update = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "active", Value: false},
+		{Key: "deactivated_at", Value: now},
+		{Key: "sessions_valid_after", Value: now},
+		{Key: "modified_at", Value: now},
+	}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if result.MatchedCount == 0 {
+		return time.Time{}, mongo.ErrNoDocuments
+	}
+	return now, nil
+}
+
+func (r *TournabyteAccountRepository) Reactivate(ctx context.Context, id bson.ObjectID, deactivatedAfter time.Time) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{
+		{Key: "_id", Value: id},
+		{Key: "active", Value: false},
+		{Key: "deactivated_at", Value: bson.D{{Key: "$gt", Value: deactivatedAfter.UTC()}}},
+	}
+	update = bson.D{
+		{Key: "$set", Value: bson.D{{Key: "active", Value: true}, {Key: "modified_at", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{{Key: "deactivated_at", Value: ""}}},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *TournabyteAccountRepository) Purge(ctx context.Context, id bson.ObjectID, deactivatedBefore time.Time) error {
+	var filter bson.D
+
+	filter = bson.D{
+		{Key: "_id", Value: id},
+		{Key: "active", Value: false},
+		{Key: "deactivated_at", Value: bson.D{{Key: "$lte", Value: deactivatedBefore.UTC()}}},
+	}
+
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (r *TournabyteAccountRepository) ResetLoginAttempts(ctx context.Context, idHex bson.ObjectID) {
 	var update bson.D
 	var filter bson.D
 
 	filter = bson.D{{Key: "_id", Value: idHex}}
-	update = bson.D{{Key: "$set", Value: bson.D{{Key: "login_attempts", Value: 0}}}}
+	update = bson.D{
+		{Key: "$set", Value: bson.D{{Key: "login_attempts", Value: 0}}},
+		{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+	}
 
 	r.collection.UpdateOne(ctx, filter, update)
 }
 
-func (r *TournabyteAccountRepository) IncrementLoginAttempts(ctx context.Context, idHex bson.ObjectID) {
+// RecordFailedLogin counts a failed login and locks the account when the policy calls for it. It returns the
+// instant the account is locked until, which is zero when the failure did not lock it.
+func (r *TournabyteAccountRepository) RecordFailedLogin(ctx context.Context, id bson.ObjectID, policy LockoutPolicy) (time.Time, error) {
+	var account Account
+	var filter bson.D
+
+	policy = policy.withDefaults()
+	now := time.Now().UTC()
+	filter = bson.D{{Key: "_id", Value: id}}
+	// A pipeline update so that restarting the count after a quiet period is decided atomically with the increment.
+	recent := bson.D{{Key: "$gt", Value: bson.A{"$last_failed_login_at", now.Add(-policy.ResetAfter)}}}
+	incremented := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$login_attempts", 0}}}, 1}}}
+	pipeline := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "login_attempts", Value: bson.D{{Key: "$cond", Value: bson.A{recent, incremented, 1}}}},
+			{Key: "last_failed_login_at", Value: now},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.collection.FindOneAndUpdate(ctx, filter, pipeline, opts).Decode(&account); err != nil {
+		return time.Time{}, err
+	}
+
+	delay := policy.Delay(account.LoginAttemptsSinceLastSuccess)
+	if delay == 0 {
+		return time.Time{}, nil
+	}
+	lockedUntil := now.Add(delay)
+	update := bson.D{{Key: "$max", Value: bson.D{{Key: "locked_until", Value: lockedUntil}}}}
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil, nil
+}
+
+// Unlock lifts the lock of an account and forgets its failed logins.
+func (r *TournabyteAccountRepository) Unlock(ctx context.Context, id bson.ObjectID) error {
 	var update bson.D
 	var filter bson.D
 
-	filter = bson.D{{Key: "_id", Value: idHex}}
-	update = bson.D{{Key: "$inc", Value: bson.D{{Key: "login_attempts", Value: 1}}}}
+	filter = bson.D{{Key: "_id", Value: id}}
+	update = bson.D{
+		{Key: "$set", Value: bson.D{{Key: "login_attempts", Value: 0}, {Key: "modified_at", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+	}
 
-	r.collection.UpdateOne(ctx, filter, update)
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// BeginTOTPEnrollment stores a secret awaiting confirmation, replacing any earlier unconfirmed one.
+func (r *TournabyteAccountRepository) BeginTOTPEnrollment(ctx context.Context, id bson.ObjectID, secret string) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "mfa_enabled", Value: bson.D{{Key: "$ne", Value: true}}}}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "totp_pending_secret", Value: secret}, {Key: "modified_at", Value: time.Now().UTC()}}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrMFAEnabled
+	}
+	return nil
+}
+
+// ConfirmTOTPEnrollment enables MFA with the pending secret once a code generated from it was checked, along with
+// the hashes of its recovery codes. The step of that code is recorded so that it cannot be replayed at login.
+func (r *TournabyteAccountRepository) ConfirmTOTPEnrollment(ctx context.Context, id bson.ObjectID, secret string, step int64, recoveryCodes []string) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "totp_pending_secret", Value: secret}}
+	update = bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "mfa_enabled", Value: true},
+			{Key: "totp_secret", Value: secret},
+			{Key: "totp_last_step", Value: step},
+			{Key: "recovery_codes", Value: recoveryCodes},
+			{Key: "modified_at", Value: time.Now().UTC()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "totp_pending_secret", Value: ""}}},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoTOTPEnrollment
+	}
+	return nil
+}
+
+// ConsumeTOTPStep records the time step of an accepted code. A step no later than the last one recorded was
+// already used, or precedes a code that was, and is refused.
+func (r *TournabyteAccountRepository) ConsumeTOTPStep(ctx context.Context, id bson.ObjectID, step int64) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{
+		{Key: "_id", Value: id},
+		{Key: "mfa_enabled", Value: true},
+		{Key: "totp_last_step", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: step}}}}},
+	}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "totp_last_step", Value: step}}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// SetRecoveryCodes replaces the recovery code hashes of an account with MFA, voiding the codes issued before.
+func (r *TournabyteAccountRepository) SetRecoveryCodes(ctx context.Context, id bson.ObjectID, recoveryCodes []string) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "mfa_enabled", Value: true}}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "recovery_codes", Value: recoveryCodes}, {Key: "modified_at", Value: time.Now().UTC()}}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrMFANotEnabled
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode removes the hash of a recovery code that matched and returns how many codes remain. Only one of
+// concurrent requests presenting the same code removes it, the others are refused.
+func (r *TournabyteAccountRepository) ConsumeRecoveryCode(ctx context.Context, id bson.ObjectID, recoveryCode string) (int, error) {
+	var account Account
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}, {Key: "mfa_enabled", Value: true}, {Key: "recovery_codes", Value: recoveryCode}}
+	update = bson.D{{Key: "$pull", Value: bson.D{{Key: "recovery_codes", Value: recoveryCode}}}}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "recovery_codes", Value: 1}})
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&account)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrRecoveryCodeUsed
+	}
+	if err != nil {
+		return 0, err
+	}
+	return account.RemainingRecoveryCodes(), nil
+}
+
+// DisableMFA forgets every second factor of the account.
+func (r *TournabyteAccountRepository) DisableMFA(ctx context.Context, id bson.ObjectID) error {
+	var update bson.D
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: id}}
+	update = bson.D{
+		{Key: "$set", Value: bson.D{{Key: "mfa_enabled", Value: false}, {Key: "modified_at", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{
+			{Key: "totp_secret", Value: ""},
+			{Key: "totp_pending_secret", Value: ""},
+			{Key: "totp_last_step", Value: ""},
+			{Key: "recovery_codes", Value: ""},
+		}},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,6 +32,31 @@ func (m *MockCollectionHandle) FindOne(ctx context.Context, filter any, opts ...
 	return args.Get(0).(*mongo.SingleResult)
 }
 
+func (m *MockCollectionHandle) UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.UpdateResult), args.Error(1)
+}
+
+func (m *MockCollectionHandle) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.SingleResult)
+}
+
+func (m *MockCollectionHandle) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.Cursor), args.Error(1)
+}
+
+func (m *MockCollectionHandle) DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*mongo.DeleteResult), args.Error(1)
+}
+
+func (m *MockCollectionHandle) UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error) {
+	args := m.Called(ctx, filter, update)
+	return args.Get(0).(*mongo.UpdateResult), args.Error(1)
+}
+
 type AccountRepositoryOperationsTestSuite struct {
 	suite.Suite
 	repo TournabyteAccountRepository
@@ -57,10 +83,113 @@ func (s *AccountRepositoryOperationsTestSuite) TestCreate() {
 
 }
 
+func (s *AccountRepositoryOperationsTestSuite) TestCreate_NormalizesEmail() {
+	ctx := context.TODO()
+	account := Account{Email: " Player@Example.IO "}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("InsertOne", ctx, &account).Return(&mongo.InsertOneResult{InsertedID: bson.NewObjectID()}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Create(ctx, &account)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "Player@Example.IO", account.Email)
+	assert.Equal(s.T(), "player@example.io", account.NormalizedEmail)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestCreate_DuplicateEmail() {
+	ctx := context.TODO()
+	account := Account{Email: "player@example.io"}
+	duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("InsertOne", ctx, &account).Return((*mongo.InsertOneResult)(nil), duplicate)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Create(ctx, &account)
+
+	assert.ErrorIs(s.T(), err, ErrAccountExists)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestCreate_InvalidEmail() {
+	mockCollection := new(MockCollectionHandle)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Create(context.TODO(), &Account{Email: "Player <player@example.io>"})
+
+	assert.ErrorIs(s.T(), err, ErrInvalidEmail)
+	mockCollection.AssertNotCalled(s.T(), "InsertOne", mock.Anything, mock.Anything)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestFindByEmail_MatchesNormalizedEmail() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "email_normalized", Value: "player@example.io"}}
+	want := Account{Id: bson.NewObjectID(), Email: "Player@example.io", NormalizedEmail: "player@example.io"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, filter).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	acc, err := s.repo.FindByEmail(ctx, "PLAYER@EXAMPLE.IO")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), &want, acc)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestFindByEmail_FallsBackToUnnormalizedAccounts() {
+	ctx := context.TODO()
+	legacyFilter := bson.D{{Key: "email", Value: "Player@example.io"}, {Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: false}}}}
+	want := Account{Id: bson.NewObjectID(), Email: "Player@example.io"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, bson.D{{Key: "email_normalized", Value: "player@example.io"}}).Return(
+		mongo.NewSingleResultFromDocument(&Account{}, mongo.ErrNoDocuments, nil),
+	)
+	mockCollection.On("FindOne", ctx, legacyFilter).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	acc, err := s.repo.FindByEmail(ctx, "Player@example.io")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), &want, acc)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestBackfillNormalizedEmails_SkipsInvalidAndColliding() {
+	ctx := context.TODO()
+	missing := bson.E{Key: "email_normalized", Value: bson.D{{Key: "$exists", Value: false}}}
+	legacy := Account{Id: bson.NewObjectID(), Email: "Player@Example.io"}
+	invalid := Account{Id: bson.NewObjectID(), Email: "not an address"}
+	colliding := Account{Id: bson.NewObjectID(), Email: "OTHER@example.io"}
+	cursor, _ := mongo.NewCursorFromDocuments([]any{legacy, invalid, colliding}, nil, nil)
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("Find", ctx, bson.D{missing}).Return(cursor, nil)
+	mockCollection.On("FindOne", ctx, bson.D{{Key: "email_normalized", Value: "player@example.io"}}).Return(
+		mongo.NewSingleResultFromDocument(&Account{}, mongo.ErrNoDocuments, nil),
+	)
+	mockCollection.On("FindOne", ctx, bson.D{{Key: "email_normalized", Value: "other@example.io"}}).Return(
+		mongo.NewSingleResultFromDocument(&Account{Id: bson.NewObjectID()}, nil, nil),
+	)
+	mockCollection.On("UpdateOne", ctx, bson.D{{Key: "_id", Value: legacy.Id}, missing},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "email_normalized", Value: "player@example.io"}}}},
+	).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	updated, skipped, err := s.repo.BackfillNormalizedEmails(ctx)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, updated)
+	assert.Equal(s.T(), []bson.ObjectID{invalid.Id, colliding.Id}, skipped)
+	mockCollection.AssertExpectations(s.T())
+}
+
 func (s *AccountRepositoryOperationsTestSuite) TestFindById_Success() {
 	ctx := context.TODO()
 	oid := bson.NewObjectID()
-	filter := bson.D{{Key: "_id", Value: oid}}
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
 	want := Account{Id: oid, Email: "test@example.com"}
 
 	mockCollection := new(MockCollectionHandle)
@@ -77,7 +206,7 @@ func (s *AccountRepositoryOperationsTestSuite) TestFindById_Success() {
 func (s *AccountRepositoryOperationsTestSuite) TestFindById_NotFound() {
 	ctx := context.TODO()
 	oid := bson.NewObjectID()
-	filter := bson.D{{Key: "_id", Value: oid}}
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
 	want := Account{Id: oid, Email: "test@example.com"}
 
 	mockCollection := new(MockCollectionHandle)
@@ -102,3 +231,459 @@ func (s *AccountRepositoryOperationsTestSuite) TestFindById_InvalidHex() {
 	assert.Nil(s.T(), acc)
 	assert.True(s.T(), errors.Is(err, bson.ErrInvalidHex))
 }
+
+func (s *AccountRepositoryOperationsTestSuite) TestUpdate_SetsAndUnsetsFields() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
+	changes := AccountChanges{
+		Set:   bson.D{{Key: "display_name", Value: "Player One"}},
+		Unset: []string{"tenant"},
+	}
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		set := update[0].Value.(bson.D)
+		return len(update) == 2 &&
+			update[0].Key == "$set" && set[0].Key == "display_name" && set[0].Value == "Player One" && set[1].Key == "modified_at" &&
+			update[1].Key == "$unset" && update[1].Value.(bson.D)[0].Key == "tenant"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Update(ctx, oid, changes)
+
+	assert.NoError(s.T(), err)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestUpdate_EmailKeepsNormalizedEmailInSync() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		set := update[0].Value.(bson.D)
+		return len(set) == 3 && set[0].Key == "email" && set[1].Key == "email_normalized" && set[1].Value == "new@example.io"
+	})
+	duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, mock.Anything, matchesUpdate).Return((*mongo.UpdateResult)(nil), duplicate)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Update(ctx, oid, AccountChanges{Set: bson.D{{Key: "email", Value: "New@Example.io"}}})
+
+	assert.ErrorIs(s.T(), err, ErrAccountExists)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestUpdate_NotFound() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Update(ctx, oid, AccountChanges{Set: bson.D{{Key: "display_name", Value: "Player One"}}})
+
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestDeactivate() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		set := update[0].Value.(bson.D)
+		return update[0].Key == "$set" && set[0].Key == "active" && set[0].Value == false && set[1].Key == "deactivated_at"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	deactivatedAt, err := s.repo.Deactivate(ctx, oid)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), deactivatedAt.IsZero())
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestReactivate_GraceElapsed() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	cutoff := time.Now().Add(-time.Hour).UTC()
+	filter := bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "active", Value: false},
+		{Key: "deactivated_at", Value: bson.D{{Key: "$gt", Value: cutoff}}},
+	}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Reactivate(ctx, oid, cutoff)
+
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestPurge() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	cutoff := time.Now().Add(-time.Hour).UTC()
+	filter := bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "active", Value: false},
+		{Key: "deactivated_at", Value: bson.D{{Key: "$lte", Value: cutoff}}},
+	}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("DeleteOne", ctx, filter).Return(&mongo.DeleteResult{DeletedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.Purge(ctx, oid, cutoff))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestList_ReportsMoreResults() {
+	ctx := context.TODO()
+	after := bson.NewObjectID()
+	active := true
+	query := AccountQuery{After: after, Limit: 2, EmailPrefix: "Test+1@Example", Active: &active}
+	filter := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$gt", Value: after}}},
+		{Key: "email_normalized", Value: bson.D{{Key: "$regex", Value: `^test\+1@example`}}},
+		{Key: "active", Value: true},
+	}
+	documents := []any{
+		Account{Id: bson.NewObjectID(), Email: "test+1@example.io", Active: true},
+		Account{Id: bson.NewObjectID(), Email: "test+1@example.com", Active: true},
+		Account{Id: bson.NewObjectID(), Email: "test+1@example.org", Active: true},
+	}
+	cursor, _ := mongo.NewCursorFromDocuments(documents, nil, nil)
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("Find", ctx, filter).Return(cursor, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	accounts, more, err := s.repo.List(ctx, query)
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), more)
+	assert.Len(s.T(), accounts, 2)
+	assert.Equal(s.T(), "test+1@example.com", accounts[1].Email)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestList_FiltersLockStateAndCreationRange() {
+	ctx := context.TODO()
+	locked := true
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	asOf := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	query := AccountQuery{Limit: 10, Locked: &locked, CreatedAfter: from, CreatedBefore: until, AsOf: asOf}
+	filter := bson.D{
+		{Key: "locked_until", Value: bson.D{{Key: "$gt", Value: asOf}}},
+		{Key: "created_at", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: until}}},
+	}
+	cursor, _ := mongo.NewCursorFromDocuments([]any{}, nil, nil)
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("Find", ctx, filter).Return(cursor, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	accounts, more, err := s.repo.List(ctx, query)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), more)
+	assert.Empty(s.T(), accounts)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestMarkEmailVerified_EmailChanged() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "email", Value: "old@example.io"}, {Key: "active", Value: true}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.MarkEmailVerified(ctx, oid, "old@example.io")
+
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestList_FiltersUnlockedAccounts() {
+	ctx := context.TODO()
+	unlocked := false
+	asOf := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	filter := bson.D{{Key: "locked_until", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: asOf}}}}}}
+	cursor, _ := mongo.NewCursorFromDocuments([]any{}, nil, nil)
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("Find", ctx, filter).Return(cursor, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	_, _, err := s.repo.List(ctx, AccountQuery{Limit: 10, Locked: &unlocked, AsOf: asOf})
+
+	assert.NoError(s.T(), err)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestLockoutPolicy_DelayDoublesUpToMax() {
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+
+	assert.Equal(s.T(), time.Duration(0), policy.Delay(2))
+	assert.Equal(s.T(), time.Minute, policy.Delay(3))
+	assert.Equal(s.T(), 2*time.Minute, policy.Delay(4))
+	assert.Equal(s.T(), 8*time.Minute, policy.Delay(6))
+	assert.Equal(s.T(), 10*time.Minute, policy.Delay(7))
+	assert.Equal(s.T(), 10*time.Minute, policy.Delay(1000))
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestLockoutPolicy_Defaults() {
+	var policy LockoutPolicy
+
+	assert.Equal(s.T(), time.Duration(0), policy.Delay(DEFAULT_LOCKOUT_THRESHOLD-1))
+	assert.Equal(s.T(), DEFAULT_LOCKOUT_BASE_DELAY, policy.Delay(DEFAULT_LOCKOUT_THRESHOLD))
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestIsLocked_ExpiresWithLock() {
+	assert.True(s.T(), (&Account{LockedUntil: time.Now().Add(time.Minute)}).IsLocked())
+	assert.False(s.T(), (&Account{LockedUntil: time.Now().Add(-time.Second), LoginAttemptsSinceLastSuccess: 50}).IsLocked())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestRecordFailedLogin_BelowThreshold() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}}
+	after := Account{Id: oid, LoginAttemptsSinceLastSuccess: 2}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, filter, mock.AnythingOfType("bson.A")).Return(mongo.NewSingleResultFromDocument(&after, nil, nil))
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	lockedUntil, err := s.repo.RecordFailedLogin(ctx, oid, LockoutPolicy{})
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), lockedUntil.IsZero())
+	mockCollection.AssertNotCalled(s.T(), "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestRecordFailedLogin_LocksWithBackoff() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}}
+	after := Account{Id: oid, LoginAttemptsSinceLastSuccess: 7}
+	policy := LockoutPolicy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		return update[0].Key == "$max" && update[0].Value.(bson.D)[0].Key == "locked_until"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, filter, mock.AnythingOfType("bson.A")).Return(mongo.NewSingleResultFromDocument(&after, nil, nil))
+	mockCollection.On("UpdateOne", ctx, filter, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	lockedUntil, err := s.repo.RecordFailedLogin(ctx, oid, policy)
+
+	assert.NoError(s.T(), err)
+	assert.WithinDuration(s.T(), time.Now().Add(4*time.Minute), lockedUntil, 5*time.Second)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestUnlock_NotFound() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, bson.D{{Key: "_id", Value: oid}}, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.Unlock(ctx, oid)
+
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestSetLoginKey_ClearsAttempts() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "active", Value: true}}
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		set := update[0].Value.(bson.D)
+		return set[0].Key == "login_key" && set[0].Value == "hash" && set[1].Key == "login_attempts" && set[1].Value == 0 &&
+			len(set) == 4 && set[3].Key == "sessions_valid_after" &&
+			update[1].Key == "$unset" && update[1].Value.(bson.D)[0].Key == "locked_until"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.SetLoginKey(ctx, oid, "hash", true))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestBeginTOTPEnrollment_AlreadyEnabled() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "mfa_enabled", Value: bson.D{{Key: "$ne", Value: true}}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	err := s.repo.BeginTOTPEnrollment(ctx, oid, "SECRET")
+
+	assert.ErrorIs(s.T(), err, ErrMFAEnabled)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestConfirmTOTPEnrollment_RecordsStep() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "totp_pending_secret", Value: "SECRET"}}
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		set := update[0].Value.(bson.D)
+		return set[0].Key == "mfa_enabled" && set[0].Value == true && set[1].Value == "SECRET" &&
+			set[2].Key == "totp_last_step" && set[2].Value == int64(42) &&
+			set[3].Key == "recovery_codes" && len(set[3].Value.([]string)) == 2 &&
+			update[1].Key == "$unset" && update[1].Value.(bson.D)[0].Key == "totp_pending_secret"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.ConfirmTOTPEnrollment(ctx, oid, "SECRET", 42, []string{"hash-1", "hash-2"}))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestConfirmTOTPEnrollment_NotPending() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.ErrorIs(s.T(), s.repo.ConfirmTOTPEnrollment(ctx, oid, "SECRET", 42, nil), ErrNoTOTPEnrollment)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestConsumeTOTPStep_Replayed() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "mfa_enabled", Value: true},
+		{Key: "totp_last_step", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: int64(42)}}}}},
+	}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.ErrorIs(s.T(), s.repo.ConsumeTOTPStep(ctx, oid, 42), ErrTOTPCodeReused)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestDisableMFA() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	matchesUpdate := mock.MatchedBy(func(update bson.D) bool {
+		unset := update[1].Value.(bson.D)
+		return update[0].Value.(bson.D)[0].Key == "mfa_enabled" && update[0].Value.(bson.D)[0].Value == false &&
+			len(unset) == 4 && unset[0].Key == "totp_secret" && unset[3].Key == "recovery_codes"
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, bson.D{{Key: "_id", Value: oid}}, matchesUpdate).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.DisableMFA(ctx, oid))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestSetRecoveryCodes_MFANotEnabled() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "mfa_enabled", Value: true}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	assert.ErrorIs(s.T(), s.repo.SetRecoveryCodes(ctx, oid, []string{"hash-1"}), ErrMFANotEnabled)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestConsumeRecoveryCode() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	filter := bson.D{{Key: "_id", Value: oid}, {Key: "mfa_enabled", Value: true}, {Key: "recovery_codes", Value: "hash-1"}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "recovery_codes", Value: "hash-1"}}}}
+	after := Account{Id: oid, RecoveryCodes: []string{"hash-2", "hash-3"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, filter, update).Return(mongo.NewSingleResultFromDocument(&after, nil, nil))
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	remaining, err := s.repo.ConsumeRecoveryCode(ctx, oid, "hash-1")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, remaining)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestConsumeRecoveryCode_AlreadyUsed() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&Account{}, mongo.ErrNoDocuments, nil),
+	)
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	_, err := s.repo.ConsumeRecoveryCode(ctx, oid, "hash-1")
+
+	assert.ErrorIs(s.T(), err, ErrRecoveryCodeUsed)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestBasicInfo_ReportsRemainingRecoveryCodes() {
+	acc := Account{MFAEnabled: true, RecoveryCodes: []string{"hash-1", "hash-2"}}
+
+	info := acc.BasicInfo()
+
+	assert.True(s.T(), info.AccountMFAEnabled)
+	assert.Equal(s.T(), 2, info.AccountRecoveryCodesRemaining)
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestIssuedBeforeRevocation_SameSecondIsRevoked() {
+	cutoff := time.Date(2026, 1, 2, 3, 4, 5, 600_000_000, time.UTC)
+	acc := Account{SessionsValidAfter: cutoff}
+
+	assert.True(s.T(), acc.IssuedBeforeRevocation(cutoff.Truncate(time.Second)))
+	assert.True(s.T(), acc.IssuedBeforeRevocation(cutoff.Add(-time.Hour)))
+	assert.False(s.T(), acc.IssuedBeforeRevocation(cutoff.Truncate(time.Second).Add(time.Second)))
+	assert.False(s.T(), (&Account{}).IssuedBeforeRevocation(cutoff))
+}
+
+func (s *AccountRepositoryOperationsTestSuite) TestFindById_ReportsLookupFailure() {
+	ctx := context.TODO()
+	oid := bson.NewObjectID()
+	lookupErr := errors.New("server selection timeout")
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, mock.Anything).Return(mongo.NewSingleResultFromDocument(&Account{}, lookupErr, nil))
+	s.repo = *NewTournabyteAccountRepository(mockCollection)
+
+	acc, err := s.repo.FindById(ctx, oid.Hex())
+
+	assert.Nil(s.T(), acc)
+	assert.ErrorIs(s.T(), err, lookupErr)
+}
@@ -0,0 +1,72 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type AuthorizationCode struct {
+	CodeHash            string        `bson:"_id"`
+	ClientId            string        `bson:"client_id"`
+	AccountId           bson.ObjectID `bson:"account_id"`
+	RedirectURI         string        `bson:"redirect_uri"`
+	Scope               string        `bson:"scope"`
+	CodeChallenge       string        `bson:"code_challenge"`
+	CodeChallengeMethod string        `bson:"code_challenge_method"`
+	Nonce               string        `bson:"nonce,omitempty"`
+	CreatedAt           time.Time     `bson:"created_at"`
+	ExpiresAt           time.Time     `bson:"expires_at"`
+	Consumed            bool          `bson:"consumed"`
+}
+
+type FindOneAndUpdateDocument interface {
+	FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) *mongo.SingleResult
+}
+
+type CreateAndConsumeOneDocument interface {
+	InsertOneDocumment
+	FindOneAndUpdateDocument
+}
+
+type TournabyteAuthorizationCodeRepository struct {
+	collection CreateAndConsumeOneDocument
+}
+
+func NewTournabyteAuthorizationCodeRepository(col CreateAndConsumeOneDocument) *TournabyteAuthorizationCodeRepository {
+	return &TournabyteAuthorizationCodeRepository{collection: col}
+}
+
+func (r *TournabyteAuthorizationCodeRepository) Create(ctx context.Context, code *AuthorizationCode, lifetime time.Duration) error {
+	code.CreatedAt = time.Now().UTC()
+	code.ExpiresAt = code.CreatedAt.Add(lifetime)
+	code.Consumed = false
+
+	_, err := r.collection.InsertOne(ctx, code)
+	return err
+}
+
+func (r *TournabyteAuthorizationCodeRepository) Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error) {
+	var code AuthorizationCode
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{
+		{Key: "_id", Value: codeHash},
+		{Key: "consumed", Value: false},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "consumed", Value: true}}}}
+
+	if consumeErr := r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&code); consumeErr != nil {
+		return nil, consumeErr
+	}
+	return &code, nil
+}
@@ -0,0 +1,75 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type AuthorizationCodeRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabyteAuthorizationCodeRepository
+}
+
+func TestAuthorizationCodeRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(AuthorizationCodeRepositoryOperationsTestSuite))
+}
+
+func (s *AuthorizationCodeRepositoryOperationsTestSuite) TestCreateSetsExpiry() {
+	ctx := context.TODO()
+	code := AuthorizationCode{CodeHash: "abc", ClientId: "client-1", Consumed: true}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("InsertOne", ctx, &code).Return(&mongo.InsertOneResult{InsertedID: code.CodeHash}, nil)
+	s.repo = *NewTournabyteAuthorizationCodeRepository(mockCollection)
+
+	err := s.repo.Create(ctx, &code, time.Minute)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), code.Consumed)
+	assert.Equal(s.T(), time.Minute, code.ExpiresAt.Sub(code.CreatedAt))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AuthorizationCodeRepositoryOperationsTestSuite) TestConsume_Success() {
+	ctx := context.TODO()
+	want := AuthorizationCode{CodeHash: "abc", ClientId: "client-1", AccountId: bson.NewObjectID()}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "consumed", Value: true}}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, update).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabyteAuthorizationCodeRepository(mockCollection)
+
+	code, err := s.repo.Consume(ctx, "abc")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), want.ClientId, code.ClientId)
+	assert.Equal(s.T(), want.AccountId, code.AccountId)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *AuthorizationCodeRepositoryOperationsTestSuite) TestConsume_AlreadyUsed() {
+	ctx := context.TODO()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&AuthorizationCode{}, mongo.ErrNoDocuments, nil),
+	)
+	s.repo = *NewTournabyteAuthorizationCodeRepository(mockCollection)
+
+	code, err := s.repo.Consume(ctx, "abc")
+
+	assert.Nil(s.T(), code)
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+}
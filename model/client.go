@@ -0,0 +1,67 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type Client struct {
+	Id           string    `bson:"_id"`
+	Name         string    `bson:"name"`
+	SecretHash   string    `bson:"secret_hash,omitempty"`
+	Public       bool      `bson:"public"`
+	RedirectURIs []string  `bson:"redirect_uris"`
+	Scopes       []string  `bson:"scopes"`
+	CreatedAt    time.Time `bson:"created_at"`
+}
+
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+func (c *Client) AllowsScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+type CreateAndReadOneDocument interface {
+	InsertOneDocumment
+	FindOneDocument
+}
+
+type TournabyteClientRepository struct {
+	collection CreateAndReadOneDocument
+}
+
+func NewTournabyteClientRepository(col CreateAndReadOneDocument) *TournabyteClientRepository {
+	return &TournabyteClientRepository{collection: col}
+}
+
+func (r *TournabyteClientRepository) Create(ctx context.Context, client *Client) error {
+	client.CreatedAt = time.Now().UTC()
+
+	_, err := r.collection.InsertOne(ctx, client)
+	return err
+}
+
+func (r *TournabyteClientRepository) FindById(ctx context.Context, clientId string) (*Client, error) {
+	var client Client
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: clientId}}
+	if findDocumentErr := r.collection.FindOne(ctx, filter).Decode(&client); findDocumentErr != nil {
+		return nil, findDocumentErr
+	}
+	return &client, nil
+}
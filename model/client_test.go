@@ -0,0 +1,99 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type ClientRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabyteClientRepository
+}
+
+func TestClientRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(ClientRepositoryOperationsTestSuite))
+}
+
+func (s *ClientRepositoryOperationsTestSuite) TestCreate() {
+	ctx := context.TODO()
+	client := Client{Id: "client-1", Name: "web"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("InsertOne", ctx, &client).Return(&mongo.InsertOneResult{InsertedID: client.Id}, nil)
+	s.repo = *NewTournabyteClientRepository(mockCollection)
+
+	err := s.repo.Create(ctx, &client)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), client.CreatedAt.IsZero())
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *ClientRepositoryOperationsTestSuite) TestFindById_Success() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "client-1"}}
+	want := Client{Id: "client-1", Name: "web", RedirectURIs: []string{"https://app.example.io/cb"}, Scopes: []string{"openid"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, filter).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabyteClientRepository(mockCollection)
+
+	client, err := s.repo.FindById(ctx, "client-1")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), &want, client)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *ClientRepositoryOperationsTestSuite) TestFindById_NotFound() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "client-1"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, filter).Return(mongo.NewSingleResultFromDocument(&Client{}, mongo.ErrNoDocuments, nil))
+	s.repo = *NewTournabyteClientRepository(mockCollection)
+
+	client, err := s.repo.FindById(ctx, "client-1")
+
+	assert.Nil(s.T(), client)
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+	mockCollection.AssertExpectations(s.T())
+}
+
+type ClientPermissionsTestSuite struct {
+	suite.Suite
+	client Client
+}
+
+func TestClientPermissions(t *testing.T) {
+	suite.Run(t, new(ClientPermissionsTestSuite))
+}
+
+func (s *ClientPermissionsTestSuite) SetupTest() {
+	s.client = Client{
+		RedirectURIs: []string{"https://app.example.io/cb"},
+		Scopes:       []string{"openid", "email"},
+	}
+}
+
+func (s *ClientPermissionsTestSuite) TestRedirectURIMustMatchExactly() {
+	assert.True(s.T(), s.client.AllowsRedirectURI("https://app.example.io/cb"))
+	assert.False(s.T(), s.client.AllowsRedirectURI("https://app.example.io/cb/"))
+	assert.False(s.T(), s.client.AllowsRedirectURI("https://evil.example.io/cb"))
+}
+
+func (s *ClientPermissionsTestSuite) TestScopesMustBeSubset() {
+	assert.True(s.T(), s.client.AllowsScopes([]string{"openid"}))
+	assert.True(s.T(), s.client.AllowsScopes([]string{"openid", "email"}))
+	assert.False(s.T(), s.client.AllowsScopes([]string{"openid", "profile"}))
+}
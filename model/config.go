@@ -5,7 +5,6 @@
 package model
 
 import (
-	"log"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -16,10 +15,74 @@ type ApplicationOptions struct {
 	Serve struct {
 		Port     int `mapstructure:"port"`
 		WebToken struct {
-			Key    string        `mapstructure:"key"`
-			Leeway time.Duration `mapstructure:"leeway"`
+			Key              string           `mapstructure:"key"`
+			Leeway           time.Duration    `mapstructure:"leeway"`
+			Algorithm        string           `mapstructure:"algorithm"`
+			KeyFile          string           `mapstructure:"keyfile"`
+			RotationInterval time.Duration    `mapstructure:"rotation"`
+			KeyRetention     time.Duration    `mapstructure:"retention"`
+			PublishDelay     time.Duration    `mapstructure:"publish_delay"`
+			KeyEncryptionKey string           `mapstructure:"key_encryption_key"`
+			PlaintextKeys    bool             `mapstructure:"plaintext_keys"`
+			Issuer           string           `mapstructure:"issuer"`
+			Audience         string           `mapstructure:"audience"`
+			ClientAudiences  []ClientAudience `mapstructure:"clients"`
+			AccessTokenTTL   time.Duration    `mapstructure:"access_ttl"`
+			RefreshTokenTTL  time.Duration    `mapstructure:"refresh_ttl"`
 		} `mapstructure:"jwt"`
 	} `mapstructure:"serve"`
+	Accounts struct {
+		PurgeGracePeriod     time.Duration `mapstructure:"purge_grace"`
+		RequireVerifiedEmail bool          `mapstructure:"require_verified_email"`
+		Password             struct {
+			MinLength         int    `mapstructure:"min_length"`
+			MaxLength         int    `mapstructure:"max_length"`
+			RequireLowercase  bool   `mapstructure:"require_lowercase"`
+			RequireUppercase  bool   `mapstructure:"require_uppercase"`
+			RequireDigit      bool   `mapstructure:"require_digit"`
+			RequireSymbol     bool   `mapstructure:"require_symbol"`
+			AllowEmailDerived bool   `mapstructure:"allow_email_derived"`
+			BreachedList      string `mapstructure:"breached_list"`
+		} `mapstructure:"password"`
+		Lockout struct {
+			Threshold  int           `mapstructure:"threshold"`
+			BaseDelay  time.Duration `mapstructure:"base_delay"`
+			MaxDelay   time.Duration `mapstructure:"max_delay"`
+			ResetAfter time.Duration `mapstructure:"reset_after"`
+		} `mapstructure:"lockout"`
+		MFA struct {
+			Issuer       string        `mapstructure:"issuer"`
+			Skew         int           `mapstructure:"skew"`
+			ChallengeTTL time.Duration `mapstructure:"challenge_ttl"`
+		} `mapstructure:"mfa"`
+	} `mapstructure:"accounts"`
+	Mail struct {
+		Driver    string `mapstructure:"driver"`
+		From      string `mapstructure:"from"`
+		Templates string `mapstructure:"templates"`
+		Directory string `mapstructure:"directory"`
+		SMTP      struct {
+			Host     string `mapstructure:"host"`
+			Port     int    `mapstructure:"port"`
+			Username string `mapstructure:"username"`
+			Password string `mapstructure:"password"`
+			StartTLS bool   `mapstructure:"starttls"`
+		} `mapstructure:"smtp"`
+	} `mapstructure:"mail"`
+	RateLimit struct {
+		Disabled       bool     `mapstructure:"disabled"`
+		Store          string   `mapstructure:"store"`
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+		ProxyHeaders   []string `mapstructure:"proxy_headers"`
+		PerIP          struct {
+			Requests int           `mapstructure:"requests"`
+			Period   time.Duration `mapstructure:"period"`
+		} `mapstructure:"per_ip"`
+		PerEmail struct {
+			Requests int           `mapstructure:"requests"`
+			Period   time.Duration `mapstructure:"period"`
+		} `mapstructure:"per_email"`
+	} `mapstructure:"ratelimit"`
 	Datastore struct {
 		Hosts    []string
 		Username string
@@ -27,6 +90,11 @@ type ApplicationOptions struct {
 	} `mapstructure:"datastore"`
 }
 
+type ClientAudience struct {
+	ClientId  string   `mapstructure:"client"`
+	Audiences []string `mapstructure:"audiences"`
+}
+
 type ApplicationConfiguration struct {
 	config *viper.Viper
 }
@@ -61,8 +129,6 @@ func (appconf ApplicationConfiguration) ApplyFlags(flags *pflag.FlagSet, optionB
 func (appconf ApplicationConfiguration) GetOptions() (*ApplicationOptions, error) {
 	var options ApplicationOptions
 
-	log.Printf("Settings: %v", appconf.config.AllSettings())
-
 	err := appconf.config.Unmarshal(&options)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,49 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var ErrInvalidEmail = errors.New("email must be a bare RFC 5322 address")
+
+var emailFolder = cases.Fold()
+
+// NormalizeEmail validates a bare address and returns the form accounts are keyed by: the local part
+// NFC-normalized and case-folded, the domain converted to lower case ASCII (punycode for IDNs).
+func NormalizeEmail(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	addr, err := mail.ParseAddress(raw)
+	if err != nil || addr.Address != raw {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	local, domain := addr.Address[:at], addr.Address[at+1:]
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEmail, err)
+	}
+	return emailFolder.String(norm.NFC.String(local)) + "@" + asciiDomain, nil
+}
+
+// normalizeEmailPrefix folds a partial address the way NormalizeEmail folds a complete one, so it can be matched
+// against email_normalized. A partial domain cannot be converted to punycode and is only lower-cased.
+func normalizeEmailPrefix(prefix string) string {
+	local, domain, hasDomain := strings.Cut(strings.TrimSpace(prefix), "@")
+	folded := emailFolder.String(norm.NFC.String(local))
+	if hasDomain {
+		folded += "@" + strings.ToLower(domain)
+	}
+	return folded
+}
@@ -0,0 +1,34 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	cases := map[string]string{
+		"player@example.io":        "player@example.io",
+		"  Player@Example.IO ":     "player@example.io",
+		"STRASSE@Bücher.example":   "strasse@xn--bcher-kva.example",
+		"Jöran@example.io":         "jöran@example.io",
+		"first.last+tag@sub.x.com": "first.last+tag@sub.x.com",
+	}
+	for raw, want := range cases {
+		got, err := NormalizeEmail(raw)
+		if assert.NoError(t, err, raw) {
+			assert.Equal(t, want, got, raw)
+		}
+	}
+}
+
+func TestNormalizeEmail_Rejects(t *testing.T) {
+	for _, raw := range []string{"", "player", "player@", "Player <player@example.io>", "a@b@c", "player@exa mple.io", "player@-bad-.io"} {
+		_, err := NormalizeEmail(raw)
+		assert.ErrorIs(t, err, ErrInvalidEmail, raw)
+	}
+}
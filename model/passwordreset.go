@@ -0,0 +1,75 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type PasswordResetToken struct {
+	TokenHash string        `bson:"_id"`
+	AccountId bson.ObjectID `bson:"account_id"`
+	Email     string        `bson:"email"`
+	CreatedAt time.Time     `bson:"created_at"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	Used      bool          `bson:"used"`
+}
+
+type PasswordResetDocumentOperations interface {
+	CreateAndConsumeOneDocument
+	FindOneDocument
+}
+
+type TournabytePasswordResetRepository struct {
+	collection PasswordResetDocumentOperations
+}
+
+func NewTournabytePasswordResetRepository(col PasswordResetDocumentOperations) *TournabytePasswordResetRepository {
+	return &TournabytePasswordResetRepository{collection: col}
+}
+
+func (r *TournabytePasswordResetRepository) Create(ctx context.Context, token *PasswordResetToken, lifetime time.Duration) error {
+	token.CreatedAt = time.Now().UTC()
+	token.ExpiresAt = token.CreatedAt.Add(lifetime)
+	token.Used = false
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func usableResetTokenFilter(tokenHash string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: tokenHash},
+		{Key: "used", Value: false},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+}
+
+// Find looks up a token that is neither used nor expired without consuming it.
+func (r *TournabytePasswordResetRepository) Find(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	var token PasswordResetToken
+
+	if findErr := r.collection.FindOne(ctx, usableResetTokenFilter(tokenHash)).Decode(&token); findErr != nil {
+		return nil, findErr
+	}
+	return &token, nil
+}
+
+func (r *TournabytePasswordResetRepository) Consume(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	var token PasswordResetToken
+	var filter bson.D
+	var update bson.D
+
+	filter = usableResetTokenFilter(tokenHash)
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "used", Value: true}}}}
+
+	if consumeErr := r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&token); consumeErr != nil {
+		return nil, consumeErr
+	}
+	return &token, nil
+}
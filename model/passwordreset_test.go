@@ -0,0 +1,92 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type PasswordResetRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabytePasswordResetRepository
+}
+
+func TestPasswordResetRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(PasswordResetRepositoryOperationsTestSuite))
+}
+
+func (s *PasswordResetRepositoryOperationsTestSuite) TestCreateSetsExpiry() {
+	ctx := context.TODO()
+	token := PasswordResetToken{TokenHash: "abc", AccountId: bson.NewObjectID(), Used: true}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("InsertOne", ctx, &token).Return(&mongo.InsertOneResult{InsertedID: token.TokenHash}, nil)
+	s.repo = *NewTournabytePasswordResetRepository(mockCollection)
+
+	err := s.repo.Create(ctx, &token, time.Hour)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), token.Used)
+	assert.Equal(s.T(), time.Hour, token.ExpiresAt.Sub(token.CreatedAt))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *PasswordResetRepositoryOperationsTestSuite) TestConsume_Success() {
+	ctx := context.TODO()
+	want := PasswordResetToken{TokenHash: "abc", AccountId: bson.NewObjectID(), Email: "test@example.io"}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "used", Value: true}}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, update).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabytePasswordResetRepository(mockCollection)
+
+	token, err := s.repo.Consume(ctx, "abc")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), want.AccountId, token.AccountId)
+	assert.Equal(s.T(), want.Email, token.Email)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *PasswordResetRepositoryOperationsTestSuite) TestFind_DoesNotConsume() {
+	ctx := context.TODO()
+	want := PasswordResetToken{TokenHash: "abc", AccountId: bson.NewObjectID(), Email: "test@example.io"}
+	matchesFilter := mock.MatchedBy(func(filter bson.D) bool {
+		return len(filter) == 3 && filter[0].Value == "abc" && filter[1].Key == "used" && filter[1].Value == false
+	})
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, matchesFilter).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabytePasswordResetRepository(mockCollection)
+
+	token, err := s.repo.Find(ctx, "abc")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), want.AccountId, token.AccountId)
+	mockCollection.AssertExpectations(s.T())
+	mockCollection.AssertNotCalled(s.T(), "FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *PasswordResetRepositoryOperationsTestSuite) TestConsume_UsedOrExpired() {
+	ctx := context.TODO()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&PasswordResetToken{}, mongo.ErrNoDocuments, nil))
+	s.repo = *NewTournabytePasswordResetRepository(mockCollection)
+
+	token, err := s.repo.Consume(ctx, "abc")
+
+	assert.Nil(s.T(), token)
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+}
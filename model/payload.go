@@ -7,31 +7,167 @@ package model
 import (
 	"time"
 
+	"github.com/tournabyte/idp/passwordpolicy"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type CreateAccountRequest struct {
 	NewAccountEmail    string `json:"email"`
-	NewAccountPassword string `json:"password"`
+	NewAccountPassword string `json:"password,omitempty"`
 }
 
 type BasicAccountInfoResponse struct {
-	AccountIdentifier  bson.ObjectID `json:"id"`
-	AccountContact     string        `json:"email"`
-	AccountCreatedTime time.Time     `json:"created"`
-	AccountModifiedAt  time.Time     `json:"modified"`
+	AccountIdentifier             bson.ObjectID `json:"id"`
+	AccountContact                string        `json:"email"`
+	AccountContactVerified        bool          `json:"email_verified"`
+	AccountCreatedTime            time.Time     `json:"created"`
+	AccountModifiedAt             time.Time     `json:"modified"`
+	AccountDisplayName            string        `json:"display_name,omitempty"`
+	AccountRoles                  []string      `json:"roles,omitempty"`
+	AccountTenant                 string        `json:"tenant,omitempty"`
+	AccountMFAEnabled             bool          `json:"mfa_enabled"`
+	AccountRecoveryCodesRemaining int           `json:"recovery_codes_remaining,omitempty"`
+}
+
+type AccountListEntry struct {
+	BasicAccountInfoResponse
+	Active          bool       `json:"active"`
+	Locked          bool       `json:"locked"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+	LastFailedLogin *time.Time `json:"last_failed_login,omitempty"`
+	DeactivatedAt   *time.Time `json:"deactivated,omitempty"`
+}
+
+type AccountListResponse struct {
+	Accounts   []AccountListEntry `json:"accounts"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type AccountDeactivationResponse struct {
+	AccountIdentifier bson.ObjectID `json:"id"`
+	DeactivatedAt     time.Time     `json:"deactivated"`
+	PurgeAfter        time.Time     `json:"purge_after"`
 }
 
 type ErrorResponse struct {
-	Reason  string `json:"reason"`
-	Message string `json:"err_msg"`
+	Reason     string                     `json:"reason"`
+	Message    string                     `json:"err_msg"`
+	Violations []passwordpolicy.Violation `json:"violations,omitempty"`
+}
+
+type EmailVerificationRequest struct {
+	Token string `json:"token"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetConfirmation struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"password"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword     string `json:"current_password"`
+	NewPassword         string `json:"new_password"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
 }
 
 type LoginAttempt struct {
 	LoginId     string `json:"authenticate_as"`
 	LoginSecret string `json:"passphrase"`
+	MFACode     string `json:"mfa_code,omitempty"`
 }
 
 type SuccessfulAuthenticationResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	Methods     []string `json:"mfa_methods"`
+	ExpiresIn   int64    `json:"expires_in"`
+}
+
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TOTPConfirmationRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RecoveryCodesRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+type MFADisableRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"`
+}
+
+type AuthorizationCodeResponse struct {
+	Code        string `json:"code"`
+	State       string `json:"state,omitempty"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+}
+
+type OAuthErrorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	State       string `json:"state,omitempty"`
+}
+
+type OpenIDProviderMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientId  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	TokenId   string   `json:"jti,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
 }
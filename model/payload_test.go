@@ -109,3 +109,55 @@ func (s *ErrorResponseEncodeTestSuite) TestEncodeStructuredDataToStream() {
 	assert.Nil(s.T(), streamErr)
 	assert.Equal(s.T(), []byte("{\"reason\":\"OOPS\",\"err_msg\":\"because of this\"}"), stream)
 }
+
+type OpenIDProviderMetadataEncodeTestSuite struct {
+	suite.Suite
+	value OpenIDProviderMetadata
+}
+
+func TestOpenIDProviderMetadataEncoding(t *testing.T) {
+	suite.Run(t, new(OpenIDProviderMetadataEncodeTestSuite))
+}
+
+func (s *OpenIDProviderMetadataEncodeTestSuite) SetupTest() {
+	s.value = OpenIDProviderMetadata{
+		Issuer:  "https://id.example.io",
+		JwksURI: "https://id.example.io/.well-known/jwks.json",
+	}
+}
+
+func (s *OpenIDProviderMetadataEncodeTestSuite) TestEncodeUsesRegisteredFieldNames() {
+	var decoded map[string]any
+	stream, streamErr := json.Marshal(s.value)
+
+	assert.Nil(s.T(), streamErr)
+	if assert.Nil(s.T(), json.Unmarshal(stream, &decoded)) {
+		assert.Equal(s.T(), "https://id.example.io", decoded["issuer"])
+		assert.Equal(s.T(), "https://id.example.io/.well-known/jwks.json", decoded["jwks_uri"])
+		assert.Contains(s.T(), decoded, "authorization_endpoint")
+		assert.Contains(s.T(), decoded, "token_endpoint")
+		assert.Contains(s.T(), decoded, "code_challenge_methods_supported")
+	}
+}
+
+type IntrospectionResponseEncodeTestSuite struct {
+	suite.Suite
+}
+
+func TestIntrospectionResponseEncoding(t *testing.T) {
+	suite.Run(t, new(IntrospectionResponseEncodeTestSuite))
+}
+
+func (s *IntrospectionResponseEncodeTestSuite) TestInactiveTokenOnlyReportsActiveFlag() {
+	stream, streamErr := json.Marshal(IntrospectionResponse{Active: false})
+
+	assert.Nil(s.T(), streamErr)
+	assert.Equal(s.T(), []byte("{\"active\":false}"), stream)
+}
+
+func (s *IntrospectionResponseEncodeTestSuite) TestActiveTokenUsesRegisteredClaimNames() {
+	stream, streamErr := json.Marshal(IntrospectionResponse{Active: true, Subject: "abc", ClientId: "client-1", ExpiresAt: 42})
+
+	assert.Nil(s.T(), streamErr)
+	assert.Equal(s.T(), []byte("{\"active\":true,\"client_id\":\"client-1\",\"sub\":\"abc\",\"exp\":42}"), stream)
+}
@@ -0,0 +1,66 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/tournabyte/idp/ratelimit"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type RateLimitBucket struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	Allowed   bool      `bson:"allowed"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// TournabyteRateLimitRepository shares token buckets between instances of the service.
+type TournabyteRateLimitRepository struct {
+	collection FindOneAndUpdateDocument
+}
+
+func NewTournabyteRateLimitRepository(col FindOneAndUpdateDocument) *TournabyteRateLimitRepository {
+	return &TournabyteRateLimitRepository{collection: col}
+}
+
+// Take refills and draws from the bucket in a single pipeline update so concurrent requests cannot overdraw it.
+// A bucket is full again one period after its last use, which is when it expires.
+func (r *TournabyteRateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error) {
+	var bucket RateLimitBucket
+	var filter bson.D
+
+	now = now.UTC()
+	capacity := float64(limit.Requests)
+	elapsedMillis := bson.D{{Key: "$max", Value: bson.A{0, bson.D{{Key: "$subtract", Value: bson.A{now, bson.D{{Key: "$ifNull", Value: bson.A{"$updated_at", now}}}}}}}}}
+	refilled := bson.D{{Key: "$min", Value: bson.A{capacity, bson.D{{Key: "$add", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$tokens", capacity}}},
+		bson.D{{Key: "$multiply", Value: bson.A{elapsedMillis, limit.RefillRate() / 1000}}},
+	}}}}}}
+	available := bson.D{{Key: "$gte", Value: bson.A{"$tokens", 1}}}
+
+	filter = bson.D{{Key: "_id", Value: key}}
+	pipeline := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: refilled},
+			{Key: "updated_at", Value: now},
+		}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "allowed", Value: available},
+			{Key: "tokens", Value: bson.D{{Key: "$cond", Value: bson.A{available, bson.D{{Key: "$subtract", Value: bson.A{"$tokens", 1}}}, "$tokens"}}}},
+			{Key: "expires_at", Value: now.Add(limit.Period)},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := r.collection.FindOneAndUpdate(ctx, filter, pipeline, opts).Decode(&bucket); err != nil {
+		return ratelimit.Decision{}, err
+	}
+	return limit.Decide(bucket.Tokens, bucket.Allowed), nil
+}
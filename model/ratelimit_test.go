@@ -0,0 +1,80 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/tournabyte/idp/ratelimit"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type RateLimitRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo  TournabyteRateLimitRepository
+	limit ratelimit.Limit
+}
+
+func TestRateLimitRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(RateLimitRepositoryOperationsTestSuite))
+}
+
+func (s *RateLimitRepositoryOperationsTestSuite) SetupTest() {
+	s.limit = ratelimit.Limit{Requests: 5, Period: time.Minute}
+}
+
+func (s *RateLimitRepositoryOperationsTestSuite) TestTake_Allowed() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "login:ip:203.0.113.9"}}
+	after := RateLimitBucket{Key: "login:ip:203.0.113.9", Tokens: 3.5, Allowed: true}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, filter, mock.AnythingOfType("bson.A")).Return(mongo.NewSingleResultFromDocument(&after, nil, nil))
+	s.repo = *NewTournabyteRateLimitRepository(mockCollection)
+
+	decision, err := s.repo.Take(ctx, "login:ip:203.0.113.9", s.limit, time.Now())
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), decision.Allowed)
+	assert.Equal(s.T(), 3, decision.Remaining)
+	assert.Equal(s.T(), 5, decision.Limit)
+	assert.Equal(s.T(), 18*time.Second, decision.Reset)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *RateLimitRepositoryOperationsTestSuite) TestTake_Denied() {
+	ctx := context.TODO()
+	after := RateLimitBucket{Key: "k", Tokens: 0.5, Allowed: false}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&after, nil, nil))
+	s.repo = *NewTournabyteRateLimitRepository(mockCollection)
+
+	decision, err := s.repo.Take(ctx, "k", s.limit, time.Now())
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), decision.Allowed)
+	assert.Equal(s.T(), 6*time.Second, decision.Reset)
+}
+
+func (s *RateLimitRepositoryOperationsTestSuite) TestTake_StoreFailure() {
+	ctx := context.TODO()
+	failure := errors.New("connection reset")
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&RateLimitBucket{}, failure, nil))
+	s.repo = *NewTournabyteRateLimitRepository(mockCollection)
+
+	_, err := s.repo.Take(ctx, "k", s.limit, time.Now())
+
+	assert.ErrorIs(s.T(), err, failure)
+}
@@ -0,0 +1,133 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrRefreshTokenReplayed       = errors.New("refresh token was already used")
+	ErrRefreshTokenClientMismatch = errors.New("refresh token was issued to another client")
+)
+
+type RefreshToken struct {
+	TokenHash string        `bson:"_id"`
+	FamilyId  string        `bson:"family_id"`
+	AccountId bson.ObjectID `bson:"account_id"`
+	ClientId  string        `bson:"client_id"`
+	Scope     string        `bson:"scope"`
+	AuthTime  time.Time     `bson:"auth_time"`
+	CreatedAt time.Time     `bson:"created_at"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	Used      bool          `bson:"used"`
+	Revoked   bool          `bson:"revoked"`
+}
+
+type UpdateManyDocuments interface {
+	UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error)
+}
+
+type RefreshTokenDocumentOperations interface {
+	InsertOneDocumment
+	FindOneDocument
+	FindOneAndUpdateDocument
+	UpdateManyDocuments
+}
+
+type TournabyteRefreshTokenRepository struct {
+	collection RefreshTokenDocumentOperations
+}
+
+func NewTournabyteRefreshTokenRepository(col RefreshTokenDocumentOperations) *TournabyteRefreshTokenRepository {
+	return &TournabyteRefreshTokenRepository{collection: col}
+}
+
+func (r *TournabyteRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken, lifetime time.Duration) error {
+	token.CreatedAt = time.Now().UTC()
+	token.ExpiresAt = token.CreatedAt.Add(lifetime)
+	token.Used = false
+	token.Revoked = false
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Redeem marks the token used, but only when it is presented by the client it was issued to. A token presented by any
+// other client is left untouched and reported as ErrRefreshTokenClientMismatch, so it can neither be burnt nor trigger
+// replay detection.
+func (r *TournabyteRefreshTokenRepository) Redeem(ctx context.Context, tokenHash string, clientId string) (*RefreshToken, error) {
+	var token RefreshToken
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{
+		{Key: "_id", Value: tokenHash},
+		{Key: "client_id", Value: clientId},
+		{Key: "used", Value: false},
+		{Key: "revoked", Value: false},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "used", Value: true}}}}
+
+	redeemErr := r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&token)
+	if redeemErr == nil {
+		return &token, nil
+	}
+	if !errors.Is(redeemErr, mongo.ErrNoDocuments) {
+		return nil, redeemErr
+	}
+
+	filter = bson.D{{Key: "_id", Value: tokenHash}}
+	if findErr := r.collection.FindOne(ctx, filter).Decode(&token); findErr != nil {
+		return nil, findErr
+	}
+	if token.ClientId != clientId {
+		return &token, ErrRefreshTokenClientMismatch
+	}
+	if token.Used {
+		return &token, ErrRefreshTokenReplayed
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *TournabyteRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	var token RefreshToken
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: tokenHash}}
+	if findErr := r.collection.FindOne(ctx, filter).Decode(&token); findErr != nil {
+		return nil, findErr
+	}
+	return &token, nil
+}
+
+func (r *TournabyteRefreshTokenRepository) RevokeAccount(ctx context.Context, accountId bson.ObjectID) error {
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{{Key: "account_id", Value: accountId}, {Key: "revoked", Value: false}}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "revoked", Value: true}}}}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *TournabyteRefreshTokenRepository) RevokeFamily(ctx context.Context, familyId string) error {
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{{Key: "family_id", Value: familyId}}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "revoked", Value: true}}}}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
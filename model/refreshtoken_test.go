@@ -0,0 +1,126 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type RefreshTokenRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabyteRefreshTokenRepository
+}
+
+func TestRefreshTokenRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(RefreshTokenRepositoryOperationsTestSuite))
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRedeem_Success() {
+	ctx := context.TODO()
+	want := RefreshToken{TokenHash: "abc", FamilyId: "family", AccountId: bson.NewObjectID(), ClientId: "client"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(mongo.NewSingleResultFromDocument(&want, nil, nil))
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	token, err := s.repo.Redeem(ctx, "abc", "client")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "family", token.FamilyId)
+	mockCollection.AssertNotCalled(s.T(), "FindOne", mock.Anything, mock.Anything)
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRedeem_ReplayReportsFamily() {
+	ctx := context.TODO()
+	used := RefreshToken{TokenHash: "abc", FamilyId: "family", ClientId: "client", Used: true}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&RefreshToken{}, mongo.ErrNoDocuments, nil),
+	)
+	mockCollection.On("FindOne", ctx, bson.D{{Key: "_id", Value: "abc"}}).Return(mongo.NewSingleResultFromDocument(&used, nil, nil))
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	token, err := s.repo.Redeem(ctx, "abc", "client")
+
+	assert.True(s.T(), errors.Is(err, ErrRefreshTokenReplayed))
+	assert.Equal(s.T(), "family", token.FamilyId)
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRedeem_ExpiredIsNotReplay() {
+	ctx := context.TODO()
+	expired := RefreshToken{TokenHash: "abc", FamilyId: "family", ClientId: "client"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.Anything, mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&RefreshToken{}, mongo.ErrNoDocuments, nil),
+	)
+	mockCollection.On("FindOne", ctx, mock.Anything).Return(mongo.NewSingleResultFromDocument(&expired, nil, nil))
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	token, err := s.repo.Redeem(ctx, "abc", "client")
+
+	assert.Nil(s.T(), token)
+	assert.True(s.T(), errors.Is(err, mongo.ErrNoDocuments))
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRedeem_OtherClientDoesNotConsume() {
+	ctx := context.TODO()
+	issued := RefreshToken{TokenHash: "abc", FamilyId: "family", ClientId: "owner"}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOneAndUpdate", ctx, mock.MatchedBy(func(filter bson.D) bool {
+		for _, e := range filter {
+			if e.Key == "client_id" {
+				return e.Value == "client"
+			}
+		}
+		return false
+	}), mock.Anything).Return(
+		mongo.NewSingleResultFromDocument(&RefreshToken{}, mongo.ErrNoDocuments, nil),
+	)
+	mockCollection.On("FindOne", ctx, mock.Anything).Return(mongo.NewSingleResultFromDocument(&issued, nil, nil))
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	token, err := s.repo.Redeem(ctx, "abc", "client")
+
+	assert.True(s.T(), errors.Is(err, ErrRefreshTokenClientMismatch))
+	assert.Equal(s.T(), "owner", token.ClientId)
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRevokeFamily() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "family_id", Value: "family"}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked", Value: true}}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateMany", ctx, filter, update).Return(&mongo.UpdateResult{ModifiedCount: 2}, nil)
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.RevokeFamily(ctx, "family"))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *RefreshTokenRepositoryOperationsTestSuite) TestRevokeAccount() {
+	ctx := context.TODO()
+	accountId := bson.NewObjectID()
+	filter := bson.D{{Key: "account_id", Value: accountId}, {Key: "revoked", Value: false}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked", Value: true}}}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateMany", ctx, filter, update).Return(&mongo.UpdateResult{ModifiedCount: 3}, nil)
+	s.repo = *NewTournabyteRefreshTokenRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.RevokeAccount(ctx, accountId))
+	mockCollection.AssertExpectations(s.T())
+}
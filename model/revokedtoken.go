@@ -0,0 +1,76 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type RevokedToken struct {
+	TokenId   string    `bson:"_id"`
+	RevokedAt time.Time `bson:"revoked_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type TournabyteRevokedTokenRepository struct {
+	collection ReadAndUpdateOneDocument
+}
+
+func NewTournabyteRevokedTokenRepository(col ReadAndUpdateOneDocument) *TournabyteRevokedTokenRepository {
+	return &TournabyteRevokedTokenRepository{collection: col}
+}
+
+func (r *TournabyteRevokedTokenRepository) Revoke(ctx context.Context, tokenId string, expiresAt time.Time) error {
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{{Key: "_id", Value: tokenId}}
+	update = bson.D{{Key: "$setOnInsert", Value: bson.D{
+		{Key: "revoked_at", Value: time.Now().UTC()},
+		{Key: "expires_at", Value: expiresAt.UTC()},
+	}}}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// Consume records a single-use token as spent and reports whether this call was the first to do so.
+func (r *TournabyteRevokedTokenRepository) Consume(ctx context.Context, tokenId string, expiresAt time.Time) (bool, error) {
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{{Key: "_id", Value: tokenId}}
+	update = bson.D{{Key: "$setOnInsert", Value: bson.D{
+		{Key: "revoked_at", Value: time.Now().UTC()},
+		{Key: "expires_at", Value: expiresAt.UTC()},
+	}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount == 1, nil
+}
+
+func (r *TournabyteRevokedTokenRepository) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	var revoked RevokedToken
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: tokenId}}
+	findDocumentErr := r.collection.FindOne(ctx, filter).Decode(&revoked)
+	if errors.Is(findDocumentErr, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if findDocumentErr != nil {
+		return false, findDocumentErr
+	}
+	return true, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type RevokedTokenRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabyteRevokedTokenRepository
+}
+
+func TestRevokedTokenRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(RevokedTokenRepositoryOperationsTestSuite))
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestRevokeUpsertsByTokenId() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "jti-1"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	assert.NoError(s.T(), s.repo.Revoke(ctx, "jti-1", time.Now().Add(time.Hour)))
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestIsRevoked() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "jti-1"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, filter).Return(mongo.NewSingleResultFromDocument(&RevokedToken{TokenId: "jti-1"}, nil, nil))
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	revoked, err := s.repo.IsRevoked(ctx, "jti-1")
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), revoked)
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestIsRevoked_NotListed() {
+	ctx := context.TODO()
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, mock.Anything).Return(mongo.NewSingleResultFromDocument(&RevokedToken{}, mongo.ErrNoDocuments, nil))
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	revoked, err := s.repo.IsRevoked(ctx, "jti-2")
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), revoked)
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestIsRevoked_PropagatesErrors() {
+	ctx := context.TODO()
+	lookupErr := errors.New("connection reset")
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("FindOne", ctx, mock.Anything).Return(mongo.NewSingleResultFromDocument(&RevokedToken{}, lookupErr, nil))
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	_, err := s.repo.IsRevoked(ctx, "jti-3")
+
+	assert.ErrorIs(s.T(), err, lookupErr)
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestConsume_FirstUse() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "jti-1"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	first, err := s.repo.Consume(ctx, "jti-1", time.Now().Add(time.Hour))
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), first)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *RevokedTokenRepositoryOperationsTestSuite) TestConsume_AlreadySpent() {
+	ctx := context.TODO()
+	filter := bson.D{{Key: "_id", Value: "jti-1"}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	s.repo = *NewTournabyteRevokedTokenRepository(mockCollection)
+
+	first, err := s.repo.Consume(ctx, "jti-1", time.Now().Add(time.Hour))
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), first)
+}
@@ -0,0 +1,140 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrSigningKeyRingConflict = errors.New("signing key ring was modified concurrently")
+
+// SigningKey holds either the PEM encoded private key or, when a key-encryption key is configured, its sealed form.
+// A key signs from ActivatesAt until RetiredAt and stays published until ExpiresAt.
+type SigningKey struct {
+	KeyId               string    `bson:"kid"`
+	Algorithm           string    `bson:"alg"`
+	PrivateKey          string    `bson:"private_key,omitempty"`
+	EncryptedPrivateKey []byte    `bson:"encrypted_private_key,omitempty"`
+	CreatedAt           time.Time `bson:"created_at"`
+	ActivatesAt         time.Time `bson:"activates_at,omitempty"`
+	RetiredAt           time.Time `bson:"retired_at,omitempty"`
+	ExpiresAt           time.Time `bson:"expires_at,omitempty"`
+}
+
+func (k *SigningKey) IsActive(now time.Time) bool {
+	return !k.ActivatesAt.After(now) && (k.RetiredAt.IsZero() || k.RetiredAt.After(now))
+}
+
+func (k *SigningKey) IsPending(now time.Time) bool {
+	return k.ActivatesAt.After(now)
+}
+
+type SigningKeyRing struct {
+	Id      string       `bson:"_id"`
+	Version int          `bson:"version"`
+	Keys    []SigningKey `bson:"keys"`
+}
+
+func (ring *SigningKeyRing) ActiveKey(now time.Time) *SigningKey {
+	for i := len(ring.Keys) - 1; i >= 0; i-- {
+		if ring.Keys[i].IsActive(now) {
+			return &ring.Keys[i]
+		}
+	}
+	return nil
+}
+
+// NewestKey is the key most recently added to the ring, which may not be signing yet.
+func (ring *SigningKeyRing) NewestKey() *SigningKey {
+	if len(ring.Keys) == 0 {
+		return nil
+	}
+	return &ring.Keys[len(ring.Keys)-1]
+}
+
+// ScheduleRotation makes next the signing key from at onwards. The keys signing until then retire at that instant
+// and stay published for retention; pending keys that never signed anything are dropped.
+func (ring *SigningKeyRing) ScheduleRotation(next SigningKey, now time.Time, at time.Time, retention time.Duration) {
+	kept := ring.Keys[:0]
+	for _, k := range ring.Keys {
+		if k.IsPending(now) {
+			continue
+		}
+		if k.RetiredAt.IsZero() {
+			k.RetiredAt = at
+			k.ExpiresAt = at.Add(retention)
+		}
+		kept = append(kept, k)
+	}
+	next.ActivatesAt = at
+	ring.Keys = append(kept, next)
+}
+
+func (ring *SigningKeyRing) PruneExpired(now time.Time) bool {
+	kept := ring.Keys[:0]
+	for _, k := range ring.Keys {
+		if k.RetiredAt.IsZero() || k.ExpiresAt.After(now) {
+			kept = append(kept, k)
+		}
+	}
+	pruned := len(kept) != len(ring.Keys)
+	ring.Keys = kept
+	return pruned
+}
+
+type ReadAndUpdateOneDocument interface {
+	FindOneDocument
+	UpdateOneDocument
+}
+
+type TournabyteSigningKeyRepository struct {
+	collection ReadAndUpdateOneDocument
+}
+
+func NewTournabyteSigningKeyRepository(col ReadAndUpdateOneDocument) *TournabyteSigningKeyRepository {
+	return &TournabyteSigningKeyRepository{collection: col}
+}
+
+func (r *TournabyteSigningKeyRepository) Load(ctx context.Context, ringId string) (*SigningKeyRing, error) {
+	var ring SigningKeyRing
+	var filter bson.D
+
+	filter = bson.D{{Key: "_id", Value: ringId}}
+	if findDocumentErr := r.collection.FindOne(ctx, filter).Decode(&ring); findDocumentErr != nil {
+		return nil, findDocumentErr
+	}
+	return &ring, nil
+}
+
+func (r *TournabyteSigningKeyRepository) Save(ctx context.Context, ring *SigningKeyRing) error {
+	var filter bson.D
+	var update bson.D
+
+	filter = bson.D{{Key: "_id", Value: ring.Id}, {Key: "version", Value: ring.Version}}
+	update = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "keys", Value: ring.Keys},
+		{Key: "version", Value: ring.Version + 1},
+	}}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSigningKeyRingConflict
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 && result.UpsertedCount == 0 {
+		return ErrSigningKeyRingConflict
+	}
+
+	ring.Version++
+	return nil
+}
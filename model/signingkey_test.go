@@ -0,0 +1,119 @@
+/*
+ * package model describes the data types utilized by the idp service
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type SigningKeyRingTestSuite struct {
+	suite.Suite
+	now  time.Time
+	ring SigningKeyRing
+}
+
+func TestSigningKeyRing(t *testing.T) {
+	suite.Run(t, new(SigningKeyRingTestSuite))
+}
+
+func (s *SigningKeyRingTestSuite) SetupTest() {
+	s.now = time.Now().UTC()
+	s.ring = SigningKeyRing{
+		Id: "token-signing",
+		Keys: []SigningKey{
+			{KeyId: "expired", RetiredAt: s.now.Add(-48 * time.Hour), ExpiresAt: s.now.Add(-time.Hour)},
+			{KeyId: "retired", RetiredAt: s.now.Add(-time.Hour), ExpiresAt: s.now.Add(time.Hour)},
+			{KeyId: "active"},
+		},
+	}
+}
+
+func (s *SigningKeyRingTestSuite) TestActiveKey() {
+	if active := s.ring.ActiveKey(s.now); assert.NotNil(s.T(), active) {
+		assert.Equal(s.T(), "active", active.KeyId)
+	}
+}
+
+func (s *SigningKeyRingTestSuite) TestScheduleRotationKeepsSigningUntilActivation() {
+	activation := s.now.Add(time.Hour)
+	s.ring.ScheduleRotation(SigningKey{KeyId: "next"}, s.now, activation, 25*time.Hour)
+
+	if active := s.ring.ActiveKey(s.now); assert.NotNil(s.T(), active) {
+		assert.Equal(s.T(), "active", active.KeyId)
+	}
+	if active := s.ring.ActiveKey(activation); assert.NotNil(s.T(), active) {
+		assert.Equal(s.T(), "next", active.KeyId)
+	}
+	assert.Equal(s.T(), activation, s.ring.Keys[2].RetiredAt)
+	assert.Equal(s.T(), activation.Add(25*time.Hour), s.ring.Keys[2].ExpiresAt)
+	assert.True(s.T(), s.ring.NewestKey().IsPending(s.now))
+}
+
+func (s *SigningKeyRingTestSuite) TestScheduleRotationReplacesPendingKey() {
+	s.ring.ScheduleRotation(SigningKey{KeyId: "next"}, s.now, s.now.Add(time.Hour), time.Hour)
+	s.ring.ScheduleRotation(SigningKey{KeyId: "replacement"}, s.now, s.now.Add(time.Hour), time.Hour)
+
+	assert.Len(s.T(), s.ring.Keys, 4)
+	assert.Equal(s.T(), "replacement", s.ring.NewestKey().KeyId)
+	assert.Equal(s.T(), "active", s.ring.ActiveKey(s.now).KeyId)
+}
+
+func (s *SigningKeyRingTestSuite) TestPruneExpiredKeepsPublishedKeys() {
+	assert.True(s.T(), s.ring.PruneExpired(s.now))
+	assert.Len(s.T(), s.ring.Keys, 2)
+	assert.Equal(s.T(), "retired", s.ring.Keys[0].KeyId)
+	assert.False(s.T(), s.ring.PruneExpired(s.now))
+}
+
+type SigningKeyRepositoryOperationsTestSuite struct {
+	suite.Suite
+	repo TournabyteSigningKeyRepository
+}
+
+func TestSigningKeyRepositoryOperations(t *testing.T) {
+	suite.Run(t, new(SigningKeyRepositoryOperationsTestSuite))
+}
+
+func (s *SigningKeyRepositoryOperationsTestSuite) TestSaveIncrementsVersion() {
+	ctx := context.TODO()
+	ring := SigningKeyRing{Id: "token-signing", Version: 3}
+	filter := bson.D{{Key: "_id", Value: "token-signing"}, {Key: "version", Value: 3}}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, filter, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	s.repo = *NewTournabyteSigningKeyRepository(mockCollection)
+
+	err := s.repo.Save(ctx, &ring)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 4, ring.Version)
+	mockCollection.AssertExpectations(s.T())
+}
+
+func (s *SigningKeyRepositoryOperationsTestSuite) TestSaveDetectsConcurrentModification() {
+	ctx := context.TODO()
+	ring := SigningKeyRing{Id: "token-signing", Version: 3}
+
+	mockCollection := new(MockCollectionHandle)
+	mockCollection.On("UpdateOne", ctx, mock.Anything, mock.Anything).Return(
+		&mongo.UpdateResult{},
+		mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}},
+	)
+	s.repo = *NewTournabyteSigningKeyRepository(mockCollection)
+
+	err := s.repo.Save(ctx, &ring)
+
+	assert.True(s.T(), errors.Is(err, ErrSigningKeyRingConflict))
+	assert.Equal(s.T(), 3, ring.Version)
+}
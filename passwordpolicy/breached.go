@@ -0,0 +1,131 @@
+/*
+ * package passwordpolicy decides whether a password is acceptable for a Tournabyte account
+ */
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	HASH_PREFIX_LENGTH = 5
+	HASH_LENGTH        = 2 * sha1.Size
+)
+
+// hashPassword returns the upper case SHA-1 of a password split into its range prefix and suffix,
+// the layout used by the Pwned Passwords k-anonymity range files.
+func hashPassword(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:HASH_PREFIX_LENGTH], hash[HASH_PREFIX_LENGTH:]
+}
+
+// parseHashLine reads "HASH[:COUNT]" lines; entries padded with a zero count are skipped.
+func parseHashLine(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	hash, count, _ := strings.Cut(line, ":")
+	if strings.TrimSpace(count) == "0" {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimSpace(hash)), true
+}
+
+// HashFile holds a breach corpus loaded from a single file of full SHA-1 hashes, indexed by range prefix.
+type HashFile struct {
+	ranges map[string][]string
+}
+
+func LoadHashFile(path string) (*HashFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadHashFile(f)
+}
+
+func ReadHashFile(r io.Reader) (*HashFile, error) {
+	list := HashFile{ranges: map[string][]string{}}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		hash, ok := parseHashLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if len(hash) != HASH_LENGTH {
+			return nil, fmt.Errorf("breached password list line %d: not a SHA-1 hash", lineNumber)
+		}
+		prefix := hash[:HASH_PREFIX_LENGTH]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[HASH_PREFIX_LENGTH:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for prefix := range list.ranges {
+		slices.Sort(list.ranges[prefix])
+	}
+	return &list, nil
+}
+
+func (l *HashFile) Contains(ctx context.Context, password string) (bool, error) {
+	prefix, suffix := hashPassword(password)
+	_, found := slices.BinarySearch(l.ranges[prefix], suffix)
+	return found, nil
+}
+
+// RangeDirectory looks passwords up in a directory of range files named after their 5 character hash
+// prefix and holding "SUFFIX:COUNT" lines, so only the file of the matching range is ever read.
+type RangeDirectory struct {
+	Directory string
+}
+
+func (d *RangeDirectory) Contains(ctx context.Context, password string) (bool, error) {
+	prefix, suffix := hashPassword(password)
+
+	f, err := os.Open(filepath.Join(d.Directory, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(d.Directory, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if hash, ok := parseHashLine(scanner.Text()); ok && hash == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// OpenBreachedPasswords loads a hash file, or serves lookups from a directory of range files.
+func OpenBreachedPasswords(path string) (BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &RangeDirectory{Directory: path}, nil
+	}
+	return LoadHashFile(path)
+}
@@ -0,0 +1,151 @@
+/*
+ * package passwordpolicy decides whether a password is acceptable for a Tournabyte account
+ */
+package passwordpolicy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DEFAULT_MIN_LENGTH = 8
+	DEFAULT_MAX_LENGTH = 128
+	EMAIL_FRAGMENT_MIN = 3
+)
+
+const (
+	RULE_MIN_LENGTH    = "min_length"
+	RULE_MAX_LENGTH    = "max_length"
+	RULE_LOWERCASE     = "lowercase"
+	RULE_UPPERCASE     = "uppercase"
+	RULE_DIGIT         = "digit"
+	RULE_SYMBOL        = "symbol"
+	RULE_EMAIL_DERIVED = "email_derived"
+	RULE_BREACHED      = "breached"
+)
+
+// Violation names a rule the password failed and explains it to the player.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// BreachedPasswords reports whether a password appears in a breach corpus.
+type BreachedPasswords interface {
+	Contains(ctx context.Context, password string) (bool, error)
+}
+
+// Policy holds the rules a password must satisfy; lengths are counted in characters. A zero MinLength or
+// MaxLength falls back to its default.
+type Policy struct {
+	MinLength         int
+	MaxLength         int
+	RequireLowercase  bool
+	RequireUppercase  bool
+	RequireDigit      bool
+	RequireSymbol     bool
+	AllowEmailDerived bool
+	Breached          BreachedPasswords
+}
+
+// Check returns every rule the password violates, or nil when it is acceptable. The email of the account
+// may be empty when it is not known yet. An error is only returned when the breach corpus is unavailable.
+func (p *Policy) Check(ctx context.Context, password string, email string) ([]Violation, error) {
+	var violations []Violation
+
+	minLength, maxLength := p.MinLength, p.MaxLength
+	if minLength <= 0 {
+		minLength = DEFAULT_MIN_LENGTH
+	}
+	if maxLength <= 0 {
+		maxLength = DEFAULT_MAX_LENGTH
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < minLength {
+		violations = append(violations, Violation{RULE_MIN_LENGTH, fmt.Sprintf("Password must hold at least %d characters", minLength)})
+	}
+	if length > maxLength {
+		violations = append(violations, Violation{RULE_MAX_LENGTH, fmt.Sprintf("Password must hold at most %d characters", maxLength)})
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, Violation{RULE_LOWERCASE, "Password must contain a lowercase letter"})
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, Violation{RULE_UPPERCASE, "Password must contain an uppercase letter"})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, Violation{RULE_DIGIT, "Password must contain a digit"})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, Violation{RULE_SYMBOL, "Password must contain a symbol or punctuation character"})
+	}
+
+	if !p.AllowEmailDerived && derivedFromEmail(password, email) {
+		violations = append(violations, Violation{RULE_EMAIL_DERIVED, "Password must not be derived from the email address"})
+	}
+
+	if p.Breached != nil && length > 0 {
+		breached, err := p.Breached.Contains(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, Violation{RULE_BREACHED, "Password appears in a known data breach"})
+		}
+	}
+	return violations, nil
+}
+
+// derivedFromEmail rejects passwords containing the address or its local part, and passwords made of the
+// local part or the domain name padded with digits and punctuation.
+func derivedFromEmail(password string, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, found := strings.Cut(email, "@")
+	if !found || password == "" {
+		return false
+	}
+	password = strings.ToLower(password)
+
+	if strings.Contains(password, email) {
+		return true
+	}
+	if utf8.RuneCountInString(local) >= EMAIL_FRAGMENT_MIN && strings.Contains(password, local) {
+		return true
+	}
+
+	domainName, _, _ := strings.Cut(domain, ".")
+	for _, fragment := range []string{lettersOf(local), lettersOf(domainName)} {
+		if utf8.RuneCountInString(fragment) >= EMAIL_FRAGMENT_MIN && lettersOf(password) == fragment {
+			return true
+		}
+	}
+	return false
+}
+
+func lettersOf(s string) string {
+	return strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) {
+			return c
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,138 @@
+/*
+ * package passwordpolicy decides whether a password is acceptable for a Tournabyte account
+ */
+package passwordpolicy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// SHA-1 of "password1" is E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D.
+const BREACHED_PASSWORD = "password1"
+
+type failingCorpus struct{}
+
+func (failingCorpus) Contains(ctx context.Context, password string) (bool, error) {
+	return false, errors.New("corpus unavailable")
+}
+
+type PolicyTestSuite struct {
+	suite.Suite
+}
+
+func TestPolicy(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func (s *PolicyTestSuite) TestDefaultsRejectShortAndEmptyPasswords() {
+	var policy Policy
+
+	for _, password := range []string{"", "short"} {
+		violations, err := policy.Check(context.TODO(), password, "")
+		s.Require().NoError(err)
+		assert.Equal(s.T(), []string{RULE_MIN_LENGTH}, rules(violations), password)
+	}
+}
+
+func (s *PolicyTestSuite) TestLengthCountsCharacters() {
+	policy := Policy{MinLength: 4, MaxLength: 6}
+
+	short, _ := policy.Check(context.TODO(), "äöü", "")
+	fits, _ := policy.Check(context.TODO(), "äöüäöü", "")
+	long, _ := policy.Check(context.TODO(), "äöüäöüä", "")
+
+	assert.Equal(s.T(), []string{RULE_MIN_LENGTH}, rules(short))
+	assert.Empty(s.T(), fits)
+	assert.Equal(s.T(), []string{RULE_MAX_LENGTH}, rules(long))
+}
+
+func (s *PolicyTestSuite) TestCharacterClasses() {
+	policy := Policy{RequireLowercase: true, RequireUppercase: true, RequireDigit: true, RequireSymbol: true}
+
+	missing, _ := policy.Check(context.TODO(), "abcdefghij", "")
+	complete, _ := policy.Check(context.TODO(), "abcDEF12 !", "")
+
+	assert.Equal(s.T(), []string{RULE_UPPERCASE, RULE_DIGIT, RULE_SYMBOL}, rules(missing))
+	assert.Empty(s.T(), complete)
+}
+
+func (s *PolicyTestSuite) TestEmailDerivedPasswords() {
+	var policy Policy
+	email := "Gamer.Tag@Tournabyte.io"
+
+	for _, password := range []string{"gamer.tag@tournabyte.io", "xxGAMER.TAGxx", "tournabyte2024!", "gamertag#1"} {
+		violations, _ := policy.Check(context.TODO(), password, email)
+		assert.Contains(s.T(), rules(violations), RULE_EMAIL_DERIVED, password)
+	}
+	violations, _ := policy.Check(context.TODO(), "correct horse battery", email)
+	assert.Empty(s.T(), violations)
+
+	policy.AllowEmailDerived = true
+	violations, _ = policy.Check(context.TODO(), "gamer.tag@tournabyte.io", email)
+	assert.Empty(s.T(), violations)
+}
+
+func (s *PolicyTestSuite) TestBreachedCorpusFailureIsAnError() {
+	policy := Policy{Breached: failingCorpus{}}
+
+	_, err := policy.Check(context.TODO(), "long enough password", "")
+
+	assert.Error(s.T(), err)
+}
+
+func (s *PolicyTestSuite) TestHashFile() {
+	corpus := strings.Join([]string{
+		"# comment",
+		"E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D:2413945",
+		"e38ad214943daad1d64c102faec29de4afe9da3e:0",
+		"",
+	}, "\n")
+	list, err := ReadHashFile(strings.NewReader(corpus))
+	s.Require().NoError(err)
+
+	policy := Policy{Breached: list}
+	violations, err := policy.Check(context.TODO(), BREACHED_PASSWORD, "")
+	s.Require().NoError(err)
+	assert.Contains(s.T(), rules(violations), RULE_BREACHED)
+
+	found, _ := list.Contains(context.TODO(), "password2")
+	assert.False(s.T(), found)
+}
+
+func (s *PolicyTestSuite) TestHashFileRejectsMalformedLines() {
+	_, err := ReadHashFile(strings.NewReader("not-a-hash:1\n"))
+
+	assert.Error(s.T(), err)
+}
+
+func (s *PolicyTestSuite) TestRangeDirectory() {
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "E38AD"), []byte("214943DAAD1D64C102FAEC29DE4AFE9DA3D:2413945\r\n"), 0o600))
+
+	corpus, err := OpenBreachedPasswords(dir)
+	s.Require().NoError(err)
+
+	breached, err := corpus.Contains(context.TODO(), BREACHED_PASSWORD)
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), breached)
+
+	unknownRange, err := corpus.Contains(context.TODO(), "another password")
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), unknownRange)
+}
@@ -0,0 +1,88 @@
+/*
+ * package ratelimit throttles requests to the Tournabyte identity provider with token buckets
+ */
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const FORWARDED_FOR_HEADER = "X-Forwarded-For"
+
+// ClientIPResolver finds the address of the client behind any trusted reverse proxies. Proxy headers are only
+// honored on connections from a trusted proxy, and X-Forwarded-For is read right to left so that entries
+// prepended by the client are ignored.
+type ClientIPResolver struct {
+	TrustedProxies []netip.Prefix
+	Headers        []string
+}
+
+func NewClientIPResolver(trustedProxies []string, headers []string) (*ClientIPResolver, error) {
+	resolver := ClientIPResolver{Headers: headers}
+	if len(resolver.Headers) == 0 {
+		resolver.Headers = []string{FORWARDED_FOR_HEADER}
+	}
+
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("trusted proxy %q is neither an address nor a CIDR prefix", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		resolver.TrustedProxies = append(resolver.TrustedProxies, prefix.Masked())
+	}
+	return &resolver, nil
+}
+
+func (c *ClientIPResolver) trusted(addr netip.Addr) bool {
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddr(raw string) (netip.Addr, bool) {
+	raw = strings.TrimSpace(raw)
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(raw, "[]"))
+	return addr.Unmap(), err == nil
+}
+
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	peer, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !c.trusted(peer) {
+		return peer.String()
+	}
+
+	for _, header := range c.Headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		hops := strings.Split(strings.Join(values, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseAddr(hops[i])
+			if !ok {
+				break
+			}
+			if i == 0 || !c.trusted(hop) {
+				return hop.String()
+			}
+		}
+	}
+	return peer.String()
+}
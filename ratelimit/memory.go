@@ -0,0 +1,63 @@
+/*
+ * package ratelimit throttles requests to the Tournabyte identity provider with token buckets
+ */
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const MEMORY_SWEEP_INTERVAL = 1 * time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     Limit
+}
+
+// MemoryStore keeps buckets in process memory; every instance of the service then enforces its own limits.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= MEMORY_SWEEP_INTERVAL {
+		s.sweep(now)
+	}
+
+	b, found := s.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = limit.Refill(b.tokens, now.Sub(b.updatedAt))
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return limit.Decide(b.tokens, allowed), nil
+}
+
+// sweep forgets buckets that have refilled completely since they are indistinguishable from new ones.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.limit.Refill(b.tokens, now.Sub(b.updatedAt)) >= float64(b.limit.Requests) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
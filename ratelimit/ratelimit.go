@@ -0,0 +1,53 @@
+/*
+ * package ratelimit throttles requests to the Tournabyte identity provider with token buckets
+ */
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit admits Requests per Period on average with bursts of up to Requests. Every bucket starts full
+// and refills continuously.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again, or until the next token when the request was denied.
+	Reset time.Duration
+}
+
+// Store takes one token from the bucket identified by key, creating it when it does not exist.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// RefillRate is the number of tokens added to a bucket per second.
+func (l Limit) RefillRate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Refill returns the tokens of a bucket after elapsed time, capped at the bucket capacity.
+func (l Limit) Refill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(l.Requests), tokens+max(elapsed.Seconds(), 0)*l.RefillRate())
+}
+
+// Decide describes a bucket holding tokens after a take that was allowed or not.
+func (l Limit) Decide(tokens float64, allowed bool) Decision {
+	decision := Decision{Allowed: allowed, Limit: l.Requests, Remaining: int(math.Floor(tokens))}
+
+	missing := float64(l.Requests) - tokens
+	if !allowed {
+		missing = 1 - tokens
+	}
+	decision.Reset = time.Duration(math.Ceil(missing / l.RefillRate() * float64(time.Second)))
+	return decision
+}
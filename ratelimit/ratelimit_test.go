@@ -0,0 +1,112 @@
+/*
+ * package ratelimit throttles requests to the Tournabyte identity provider with token buckets
+ */
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	limit Limit
+	now   time.Time
+}
+
+func TestRateLimit(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+func (s *RateLimitTestSuite) SetupTest() {
+	s.limit = Limit{Requests: 3, Period: time.Minute}
+	s.now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *RateLimitTestSuite) TestMemoryStoreAllowsBurstThenDenies() {
+	store := NewMemoryStore()
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision, err := store.Take(context.TODO(), "ip:203.0.113.9", s.limit, s.now)
+		s.Require().NoError(err)
+		assert.True(s.T(), decision.Allowed)
+		assert.Equal(s.T(), remaining, decision.Remaining)
+	}
+
+	denied, _ := store.Take(context.TODO(), "ip:203.0.113.9", s.limit, s.now)
+	assert.False(s.T(), denied.Allowed)
+	assert.Equal(s.T(), 20*time.Second, denied.Reset)
+
+	other, _ := store.Take(context.TODO(), "ip:203.0.113.10", s.limit, s.now)
+	assert.True(s.T(), other.Allowed)
+}
+
+func (s *RateLimitTestSuite) TestMemoryStoreRefills() {
+	store := NewMemoryStore()
+	for range 3 {
+		store.Take(context.TODO(), "k", s.limit, s.now)
+	}
+
+	decision, _ := store.Take(context.TODO(), "k", s.limit, s.now.Add(20*time.Second))
+
+	assert.True(s.T(), decision.Allowed)
+	assert.Equal(s.T(), 0, decision.Remaining)
+	assert.Equal(s.T(), time.Minute, decision.Reset)
+}
+
+func (s *RateLimitTestSuite) TestMemoryStoreSweepsFullBuckets() {
+	store := NewMemoryStore()
+	store.Take(context.TODO(), "k", s.limit, s.now)
+
+	store.Take(context.TODO(), "other", s.limit, s.now.Add(2*time.Minute))
+
+	assert.Len(s.T(), store.buckets, 1)
+}
+
+func (s *RateLimitTestSuite) TestRefillIsCapped() {
+	assert.Equal(s.T(), 3.0, s.limit.Refill(0, time.Hour))
+	assert.Equal(s.T(), 1.5, s.limit.Refill(1, 10*time.Second))
+}
+
+func (s *RateLimitTestSuite) TestClientIPIgnoresHeadersFromUntrustedPeers() {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"}, nil)
+	s.Require().NoError(err)
+	r := httptest.NewRequest("POST", "/accounts/authtoken", nil)
+	r.RemoteAddr = "198.51.100.7:51234"
+	r.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+	assert.Equal(s.T(), "198.51.100.7", resolver.ClientIP(r))
+}
+
+func (s *RateLimitTestSuite) TestClientIPSkipsTrustedHopsFromTheRight() {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "2001:db8::1"}, nil)
+	s.Require().NoError(err)
+	r := httptest.NewRequest("POST", "/accounts/authtoken", nil)
+	r.RemoteAddr = "[2001:db8::1]:443"
+	r.Header.Add("X-Forwarded-For", "192.0.2.66, 203.0.113.5")
+	r.Header.Add("X-Forwarded-For", "10.1.2.3")
+
+	assert.Equal(s.T(), "203.0.113.5", resolver.ClientIP(r))
+}
+
+func (s *RateLimitTestSuite) TestClientIPHonorsConfiguredHeaders() {
+	resolver, err := NewClientIPResolver([]string{"127.0.0.1"}, []string{"CF-Connecting-IP"})
+	s.Require().NoError(err)
+	r := httptest.NewRequest("POST", "/accounts", nil)
+	r.RemoteAddr = "127.0.0.1:8080"
+	r.Header.Set("X-Forwarded-For", "192.0.2.1")
+	r.Header.Set("CF-Connecting-IP", "198.51.100.20")
+
+	assert.Equal(s.T(), "198.51.100.20", resolver.ClientIP(r))
+}
+
+func (s *RateLimitTestSuite) TestClientIPRejectsMalformedProxies() {
+	_, err := NewClientIPResolver([]string{"not-an-address"}, nil)
+
+	assert.Error(s.T(), err)
+}
@@ -0,0 +1,101 @@
+/*
+ * package totp implements the time-based one-time passwords of RFC 6238 used as a second factor
+ */
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SECRET_BYTES = 20
+	DIGITS       = 6
+	PERIOD       = 30 * time.Second
+	ALGORITHM    = "SHA1"
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random shared secret encoded the way authenticator apps expect it.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, SECRET_BYTES)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(buf), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return secretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "=")))
+}
+
+// Step returns the time step the instant falls in.
+func Step(now time.Time) int64 {
+	return now.Unix() / int64(PERIOD/time.Second)
+}
+
+// Code computes the one-time password of the time step with HOTP (RFC 4226).
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("malformed totp secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for range DIGITS {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", DIGITS, truncated%modulus), nil
+}
+
+// Validate looks for the time step within skew steps either side of now whose code matches. The step is returned
+// so that callers can refuse codes of steps that were already used.
+func Validate(secret string, code string, now time.Time, skew int) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != DIGITS {
+		return 0, false
+	}
+
+	current := Step(now)
+	for delta := -int64(skew); delta <= int64(skew); delta++ {
+		expected, err := Code(secret, current+delta)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + delta, true
+		}
+	}
+	return 0, false
+}
+
+// URI builds the otpauth:// key URI authenticator apps import, usually from a QR code.
+func URI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", ALGORITHM)
+	query.Set("digits", fmt.Sprint(DIGITS))
+	query.Set("period", fmt.Sprint(int(PERIOD/time.Second)))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	// Authenticator apps do not decode '+' in query values as a space.
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
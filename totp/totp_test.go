@@ -0,0 +1,91 @@
+/*
+ * package totp implements the time-based one-time passwords of RFC 6238 used as a second factor
+ */
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TOTPTestSuite struct {
+	suite.Suite
+	secret string
+}
+
+func TestTOTP(t *testing.T) {
+	suite.Run(t, new(TOTPTestSuite))
+}
+
+func (s *TOTPTestSuite) SetupTest() {
+	// The SHA1 seed of the RFC 6238 test vectors.
+	s.secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+}
+
+func (s *TOTPTestSuite) TestCodeMatchesRFCVectors() {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := Code(s.secret, Step(time.Unix(unix, 0)))
+		s.Require().NoError(err)
+		assert.Equal(s.T(), expected, code, "time %d", unix)
+	}
+}
+
+func (s *TOTPTestSuite) TestValidateWithinSkew() {
+	now := time.Unix(1111111111, 0)
+	previous, _ := Code(s.secret, Step(now)-1)
+
+	step, ok := Validate(s.secret, previous, now, 1)
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), Step(now)-1, step)
+
+	_, ok = Validate(s.secret, previous, now, 0)
+	assert.False(s.T(), ok)
+}
+
+func (s *TOTPTestSuite) TestValidateRejectsMalformedCodes() {
+	now := time.Unix(1111111111, 0)
+
+	for _, code := range []string{"", "05047", "0504711", "abcdef"} {
+		_, ok := Validate(s.secret, code, now, 1)
+		assert.False(s.T(), ok, code)
+	}
+	_, ok := Validate("not base32!", "050471", now, 1)
+	assert.False(s.T(), ok)
+}
+
+func (s *TOTPTestSuite) TestGenerateSecretIsUsable() {
+	secret, err := GenerateSecret()
+	s.Require().NoError(err)
+	assert.Len(s.T(), secret, 32)
+
+	code, err := Code(secret, Step(time.Now()))
+	s.Require().NoError(err)
+	_, ok := Validate(secret, code, time.Now(), 1)
+	assert.True(s.T(), ok)
+}
+
+func (s *TOTPTestSuite) TestURI() {
+	uri := URI("Tournabyte Arena", "player@example.com", s.secret)
+
+	parsed, err := url.Parse(uri)
+	s.Require().NoError(err)
+	assert.Equal(s.T(), "otpauth", parsed.Scheme)
+	assert.Equal(s.T(), "totp", parsed.Host)
+	assert.Equal(s.T(), "/Tournabyte Arena:player@example.com", parsed.Path)
+	assert.Equal(s.T(), s.secret, parsed.Query().Get("secret"))
+	assert.Equal(s.T(), "Tournabyte Arena", parsed.Query().Get("issuer"))
+	assert.Equal(s.T(), "6", parsed.Query().Get("digits"))
+	assert.NotContains(s.T(), uri, "+")
+}
@@ -0,0 +1,71 @@
+/*
+ * package verifier lets Go resource servers validate tokens issued by the Tournabyte identity provider
+ */
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const CLAIMS_CONTEXT_KEY contextKey = "VERIFIED_CLAIMS"
+
+type errorResponse struct {
+	Reason  string `json:"reason"`
+	Message string `json:"err_msg"`
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(CLAIMS_CONTEXT_KEY).(*Claims)
+	return claims, ok
+}
+
+func BearerToken(r *http.Request) (string, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
+
+func (v *Verifier) Handler(next http.Handler, scopes ...string) http.Handler {
+	challenge := `Bearer`
+	if len(scopes) > 0 {
+		challenge = fmt.Sprintf(`Bearer scope="%s"`, strings.Join(scopes, " "))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := BearerToken(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeError(w, http.StatusUnauthorized, errorResponse{Reason: "BEARER_TOKEN_REQUIRED", Message: "An Authorization: Bearer token is required"})
+			return
+		}
+
+		claims, err := v.Verify(r.Context(), raw)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", challenge+`, error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, errorResponse{Reason: "BEARER_TOKEN_INVALID", Message: "Bearer token is invalid or expired"})
+			return
+		}
+
+		if !claims.HasScopes(scopes...) {
+			w.Header().Set("WWW-Authenticate", challenge+`, error="insufficient_scope"`)
+			writeError(w, http.StatusForbidden, errorResponse{Reason: "INSUFFICIENT_SCOPE", Message: "Bearer token was not granted the required scope"})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), CLAIMS_CONTEXT_KEY, claims)))
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, body errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
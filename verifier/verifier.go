@@ -0,0 +1,212 @@
+/*
+ * package verifier lets Go resource servers validate tokens issued by the Tournabyte identity provider
+ */
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	DEFAULT_CACHE_TTL        = 5 * time.Minute
+	DEFAULT_REFRESH_INTERVAL = 30 * time.Second
+	DISCOVERY_PATH           = "/.well-known/openid-configuration"
+)
+
+var SUPPORTED_ALGORITHMS = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.EdDSA}
+
+// NON_ACCESS_TOKEN_CLAIMS mark tokens the IdP signs for other purposes, such as MFA challenges, email
+// verification links and ID tokens. A token carrying any of them is never accepted as an access token.
+var NON_ACCESS_TOKEN_CLAIMS = []string{"purpose", "nonce"}
+
+var (
+	ErrMissingToken = errors.New("verifier: bearer token missing")
+	ErrInvalidToken = errors.New("verifier: token invalid")
+	ErrUnknownKey   = errors.New("verifier: token signed by an unknown key")
+)
+
+type Config struct {
+	Issuer          string
+	Audience        string
+	JWKSURL         string
+	Leeway          time.Duration
+	CacheTTL        time.Duration
+	RefreshInterval time.Duration
+	HTTPClient      *http.Client
+}
+
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	TokenId   string
+	Scope     string
+	ClientId  string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	NotBefore time.Time
+	Extra     map[string]any
+}
+
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func (c *Claims) HasScopes(scopes ...string) bool {
+	granted := c.Scopes()
+	for _, s := range scopes {
+		if !slices.Contains(granted, s) {
+			return false
+		}
+	}
+	return true
+}
+
+type Verifier struct {
+	config      Config
+	mu          sync.Mutex
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	lastRefresh time.Time
+}
+
+func New(config Config) (*Verifier, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("verifier: an issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("verifier: an audience is required")
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DEFAULT_CACHE_TTL
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DEFAULT_REFRESH_INTERVAL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Verifier{config: config}, nil
+}
+
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	token, parseErr := jwt.ParseSigned(raw, SUPPORTED_ALGORITHMS)
+	if parseErr != nil || len(token.Headers) != 1 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, parseErr)
+	}
+
+	key, keyErr := v.key(ctx, token.Headers[0].KeyID)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	var registered jwt.Claims
+	var extra map[string]any
+	if claimsErr := token.Claims(key.Key, &registered, &extra); claimsErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, claimsErr)
+	}
+
+	for _, name := range NON_ACCESS_TOKEN_CLAIMS {
+		if _, present := extra[name]; present {
+			return nil, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+		}
+	}
+
+	expected := jwt.Expected{Issuer: v.config.Issuer, AnyAudience: jwt.Audience{v.config.Audience}, Time: time.Now()}
+	if validateErr := registered.ValidateWithLeeway(expected, v.config.Leeway); validateErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, validateErr)
+	}
+
+	claims := Claims{
+		Subject:  registered.Subject,
+		Issuer:   registered.Issuer,
+		Audience: registered.Audience,
+		TokenId:  registered.ID,
+		Extra:    extra,
+	}
+	claims.Scope, _ = extra["scope"].(string)
+	claims.ClientId, _ = extra["client_id"].(string)
+	if registered.Expiry != nil {
+		claims.ExpiresAt = registered.Expiry.Time()
+	}
+	if registered.IssuedAt != nil {
+		claims.IssuedAt = registered.IssuedAt.Time()
+	}
+	if registered.NotBefore != nil {
+		claims.NotBefore = registered.NotBefore.Time()
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) key(ctx context.Context, kid string) (jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	stale := time.Since(v.fetchedAt) > v.config.CacheTTL
+	if keys := v.keys.Key(kid); len(keys) > 0 && !stale {
+		return keys[0], nil
+	}
+
+	if stale || time.Since(v.lastRefresh) > v.config.RefreshInterval {
+		if err := v.refresh(ctx); err != nil && len(v.keys.Keys) == 0 {
+			return jose.JSONWebKey{}, err
+		}
+	}
+
+	if keys := v.keys.Key(kid); len(keys) > 0 {
+		return keys[0], nil
+	}
+	return jose.JSONWebKey{}, ErrUnknownKey
+}
+
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.lastRefresh = time.Now()
+
+	if v.config.JWKSURL == "" {
+		var metadata struct {
+			JwksURI string `json:"jwks_uri"`
+		}
+		if err := v.fetchJSON(ctx, strings.TrimSuffix(v.config.Issuer, "/")+DISCOVERY_PATH, &metadata); err != nil {
+			return fmt.Errorf("verifier: discovery failed: %w", err)
+		}
+		v.config.JWKSURL = metadata.JwksURI
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := v.fetchJSON(ctx, v.config.JWKSURL, &keys); err != nil {
+		return fmt.Errorf("verifier: fetching JWKS failed: %w", err)
+	}
+
+	v.keys = keys
+	v.fetchedAt = v.lastRefresh
+	return nil
+}
+
+func (v *Verifier) fetchJSON(ctx context.Context, url string, into any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
@@ -0,0 +1,189 @@
+/*
+ * package verifier lets Go resource servers validate tokens issued by the Tournabyte identity provider
+ */
+package verifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tournabyte/idp/verifier/verifiertest"
+)
+
+type VerifierTestSuite struct {
+	suite.Suite
+	idp      *verifiertest.Server
+	verifier *Verifier
+}
+
+func TestVerifier(t *testing.T) {
+	suite.Run(t, new(VerifierTestSuite))
+}
+
+func (s *VerifierTestSuite) SetupTest() {
+	s.idp = verifiertest.NewServer()
+	v, err := New(Config{Issuer: s.idp.Issuer(), Audience: verifiertest.AUDIENCE, JWKSURL: s.idp.JWKSURL()})
+	s.Require().NoError(err)
+	s.verifier = v
+}
+
+func (s *VerifierTestSuite) TearDownTest() {
+	s.idp.Close()
+}
+
+func (s *VerifierTestSuite) TestVerifyExposesTypedClaims() {
+	raw := s.idp.Issue(jwt.Claims{Subject: "player-1", ID: "jti-1"}, map[string]any{"scope": "openid matches:report", "client_id": "bot"})
+
+	claims, err := s.verifier.Verify(context.TODO(), raw)
+
+	if assert.NoError(s.T(), err) {
+		assert.Equal(s.T(), "player-1", claims.Subject)
+		assert.Equal(s.T(), "jti-1", claims.TokenId)
+		assert.Equal(s.T(), "bot", claims.ClientId)
+		assert.True(s.T(), claims.HasScopes("matches:report"))
+		assert.False(s.T(), claims.HasScopes("admin"))
+	}
+}
+
+func (s *VerifierTestSuite) TestVerifyCachesKeys() {
+	for range 3 {
+		_, err := s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+		assert.NoError(s.T(), err)
+	}
+	assert.Equal(s.T(), 1, s.idp.KeyFetches())
+}
+
+func (s *VerifierTestSuite) TestVerifyRefetchesKeysAfterRotation() {
+	_, err := s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+	s.Require().NoError(err)
+
+	s.idp.Rotate(true)
+	s.verifier.lastRefresh = time.Time{}
+	_, err = s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, s.idp.KeyFetches())
+}
+
+func (s *VerifierTestSuite) TestVerifyRejectsUnknownKeyWithinRefreshInterval() {
+	_, err := s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+	s.Require().NoError(err)
+
+	s.idp.Rotate(false)
+	_, err = s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+
+	assert.True(s.T(), errors.Is(err, ErrUnknownKey))
+	assert.Equal(s.T(), 1, s.idp.KeyFetches())
+}
+
+func (s *VerifierTestSuite) TestVerifyRejectsExpiredToken() {
+	raw := s.idp.Issue(jwt.Claims{Subject: "player-1", Expiry: jwt.NewNumericDate(time.Now().Add(-time.Hour))}, nil)
+
+	_, err := s.verifier.Verify(context.TODO(), raw)
+
+	assert.True(s.T(), errors.Is(err, ErrInvalidToken))
+}
+
+func (s *VerifierTestSuite) TestVerifyHonoursLeeway() {
+	s.verifier.config.Leeway = 2 * time.Minute
+	raw := s.idp.Issue(jwt.Claims{Subject: "player-1", Expiry: jwt.NewNumericDate(time.Now().Add(-time.Minute))}, nil)
+
+	_, err := s.verifier.Verify(context.TODO(), raw)
+
+	assert.NoError(s.T(), err)
+}
+
+func (s *VerifierTestSuite) TestVerifyRejectsWrongIssuerAndAudience() {
+	_, issuerErr := s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Issuer: "https://evil.example.io"}, nil))
+	_, audienceErr := s.verifier.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Audience: jwt.Audience{"other"}}, nil))
+
+	assert.True(s.T(), errors.Is(issuerErr, ErrInvalidToken))
+	assert.True(s.T(), errors.Is(audienceErr, ErrInvalidToken))
+}
+
+func (s *VerifierTestSuite) TestVerifyRejectsPurposeTokens() {
+	raw := s.idp.Issue(jwt.Claims{Subject: "player-1"}, map[string]any{"purpose": "mfa_challenge"})
+
+	_, err := s.verifier.Verify(context.TODO(), raw)
+
+	assert.True(s.T(), errors.Is(err, ErrInvalidToken))
+}
+
+func (s *VerifierTestSuite) TestNewRequiresAudience() {
+	_, err := New(Config{Issuer: s.idp.Issuer()})
+
+	assert.Error(s.T(), err)
+}
+
+func (s *VerifierTestSuite) TestVerifyDiscoversKeySet() {
+	v, err := New(Config{Issuer: s.idp.Issuer(), Audience: verifiertest.AUDIENCE})
+	s.Require().NoError(err)
+
+	_, err = v.Verify(context.TODO(), s.idp.Issue(jwt.Claims{Subject: "player-1"}, nil))
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.idp.JWKSURL(), v.config.JWKSURL)
+}
+
+type HandlerTestSuite struct {
+	suite.Suite
+	idp     *verifiertest.Server
+	handler http.Handler
+}
+
+func TestHandler(t *testing.T) {
+	suite.Run(t, new(HandlerTestSuite))
+}
+
+func (s *HandlerTestSuite) SetupTest() {
+	s.idp = verifiertest.NewServer()
+	v, err := New(Config{Issuer: s.idp.Issuer(), Audience: verifiertest.AUDIENCE, JWKSURL: s.idp.JWKSURL()})
+	s.Require().NoError(err)
+
+	s.handler = v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject))
+	}), "matches:report")
+}
+
+func (s *HandlerTestSuite) TearDownTest() {
+	s.idp.Close()
+}
+
+func (s *HandlerTestSuite) serve(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/matches", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *HandlerTestSuite) TestMissingToken() {
+	rec := s.serve("")
+
+	assert.Equal(s.T(), http.StatusUnauthorized, rec.Code)
+	assert.Contains(s.T(), rec.Header().Get("WWW-Authenticate"), "Bearer")
+}
+
+func (s *HandlerTestSuite) TestInsufficientScope() {
+	rec := s.serve(s.idp.Issue(jwt.Claims{Subject: "player-1"}, map[string]any{"scope": "openid"}))
+
+	assert.Equal(s.T(), http.StatusForbidden, rec.Code)
+	assert.Contains(s.T(), rec.Header().Get("WWW-Authenticate"), "insufficient_scope")
+}
+
+func (s *HandlerTestSuite) TestClaimsReachHandler() {
+	rec := s.serve(s.idp.Issue(jwt.Claims{Subject: "player-1"}, map[string]any{"scope": "matches:report"}))
+
+	assert.Equal(s.T(), http.StatusOK, rec.Code)
+	assert.Equal(s.T(), "player-1", rec.Body.String())
+}
@@ -0,0 +1,133 @@
+/*
+ * package verifiertest provides a fake identity provider serving a JWKS for testing resource servers
+ */
+package verifiertest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	JWKS_PATH      = "/.well-known/jwks.json"
+	DISCOVERY_PATH = "/.well-known/openid-configuration"
+	AUDIENCE       = "verifiertest-audience"
+)
+
+type Server struct {
+	*httptest.Server
+	mu        sync.Mutex
+	key       jose.JSONWebKey
+	signer    jose.Signer
+	published []jose.JSONWebKey
+	fetches   atomic.Int32
+}
+
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+JWKS_PATH, s.serveKeys)
+	mux.HandleFunc("GET "+DISCOVERY_PATH, s.serveDiscovery)
+	s.Server = httptest.NewServer(mux)
+	s.Rotate(false)
+	return s
+}
+
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+func (s *Server) JWKSURL() string {
+	return s.URL + JWKS_PATH
+}
+
+func (s *Server) KeyFetches() int {
+	return int(s.fetches.Load())
+}
+
+// Rotate replaces the signing key, keeping the previous public key published when keepPrevious is set.
+func (s *Server) Rotate(keepPrevious bool) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("verifiertest: key generation failed: %v", err))
+	}
+	key := jose.JSONWebKey{Key: priv, Algorithm: string(jose.ES256), Use: "sig"}
+	thumbprint, _ := key.Thumbprint(crypto.SHA256)
+	key.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		panic(fmt.Sprintf("verifiertest: signer creation failed: %v", err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !keepPrevious {
+		s.published = nil
+	}
+	s.key = key
+	s.signer = signer
+	s.published = append(s.published, key.Public())
+}
+
+// Issue signs a token with the current key. Unset issuer, audience, expiry and issued-at claims are
+// filled with values a verifier configured for this server accepts.
+func (s *Server) Issue(claims jwt.Claims, extra map[string]any) string {
+	if claims.Issuer == "" {
+		claims.Issuer = s.Issuer()
+	}
+	if claims.Audience == nil {
+		claims.Audience = jwt.Audience{AUDIENCE}
+	}
+	if claims.Expiry == nil {
+		claims.Expiry = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	}
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	}
+
+	s.mu.Lock()
+	signer := s.signer
+	s.mu.Unlock()
+
+	builder := jwt.Signed(signer).Claims(claims)
+	if extra != nil {
+		builder = builder.Claims(extra)
+	}
+	raw, err := builder.Serialize()
+	if err != nil {
+		panic(fmt.Sprintf("verifiertest: token creation failed: %v", err))
+	}
+	return raw
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	keySet := jose.JSONWebKeySet{Keys: append([]jose.JSONWebKey(nil), s.published...)}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keySet)
+}
+
+func (s *Server) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":   s.Issuer(),
+		"jwks_uri": s.JWKSURL(),
+	})
+}